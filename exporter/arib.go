@@ -0,0 +1,57 @@
+// Copyright 2021 coord_e
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//  	 http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+// serviceKind returns a readable kind of the ARIB service_type (ARIB STD-B10).
+func serviceKind(serviceType int) string {
+	switch serviceType {
+	case 0x01, 0xA1, 0xA5, 0xAD:
+		return "tv"
+	case 0x02, 0xA2, 0xA6:
+		return "radio"
+	case 0xA3, 0xA7, 0xA8, 0xA9, 0xAA, 0xAC, 0xC0:
+		return "data"
+	case 0xA4:
+		return "engineering"
+	case 0xAB:
+		return "server"
+	default:
+		return "unknown"
+	}
+}
+
+// genreNames maps content_nibble_level_1 of the ARIB content descriptor (ARIB STD-B10) to readable names.
+var genreNames = map[int]string{
+	0x0: "news",
+	0x1: "sports",
+	0x2: "information",
+	0x3: "drama",
+	0x4: "music",
+	0x5: "variety",
+	0x6: "movie",
+	0x7: "anime",
+	0x8: "documentary",
+	0x9: "theater",
+	0xA: "hobby",
+	0xB: "welfare",
+	0xE: "extension",
+	0xF: "other",
+}
+
+func genreName(lv1 int) string {
+	if name, ok := genreNames[lv1]; ok {
+		return name
+	}
+	return "unknown"
+}
@@ -0,0 +1,93 @@
+// Copyright 2021 coord_e
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//  	 http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/coord-e/mirakurun_exporter/mirakurun"
+)
+
+type channelsExporter struct {
+	ctx    context.Context
+	client *mirakurun.Client
+	logger log.Logger
+
+	channels      *prometheus.Desc
+	emptyChannels *prometheus.Desc
+	services      *prometheus.Desc
+}
+
+// Verify if channelsExporter implements prometheus.Collector
+var _ prometheus.Collector = (*channelsExporter)(nil)
+
+func newChannelsExporter(ctx context.Context, client *mirakurun.Client, logger log.Logger) *channelsExporter {
+	const subsystem = "channels"
+
+	return &channelsExporter{
+		ctx:    ctx,
+		client: client,
+		logger: logger,
+
+		channels: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "channels"),
+			"Number of channels configured in Mirakurun.",
+			[]string{"channel_type"}, nil),
+		emptyChannels: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "empty_channels"),
+			"Number of channels configured in Mirakurun without any discovered services.",
+			[]string{"channel_type"}, nil),
+		services: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "services"),
+			"Number of services discovered in the channel.",
+			[]string{"channel_type", "channel"}, nil),
+	}
+}
+
+func (e *channelsExporter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- e.channels
+	ch <- e.emptyChannels
+	ch <- e.services
+}
+
+func (e *channelsExporter) Collect(ch chan<- prometheus.Metric) {
+	channels, err := e.client.GetChannels(e.ctx)
+	if err != nil {
+		level.Error(e.logger).Log("msg", "failed to fetch Mirakurun channels", "err", err)
+		return
+	}
+
+	counts := map[string]int{}
+	emptyCounts := map[string]int{}
+	services := map[[2]string]int{}
+	for _, channel := range *channels {
+		counts[channel.Type]++
+		if len(channel.Services) == 0 {
+			emptyCounts[channel.Type]++
+		}
+		services[[2]string{channel.Type, channel.Channel}] += len(channel.Services)
+	}
+
+	for channelType, count := range counts {
+		ch <- prometheus.MustNewConstMetric(e.channels, prometheus.GaugeValue, float64(count), channelType)
+		ch <- prometheus.MustNewConstMetric(e.emptyChannels, prometheus.GaugeValue, float64(emptyCounts[channelType]), channelType)
+	}
+	for key, count := range services {
+		ch <- prometheus.MustNewConstMetric(e.services, prometheus.GaugeValue, float64(count), key[0], key[1])
+	}
+}
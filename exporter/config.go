@@ -0,0 +1,225 @@
+// Copyright 2021 coord_e
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//  	 http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/coord-e/mirakurun_exporter/mirakurun"
+)
+
+type configExporter struct {
+	ctx    context.Context
+	client *mirakurun.Client
+	filter ServiceFilter
+	logger log.Logger
+
+	epgGatheringInterval      *prometheus.Desc
+	epgRetrievalTime          *prometheus.Desc
+	programGCInterval         *prometheus.Desc
+	logoDataInterval          *prometheus.Desc
+	eventEndTimeout           *prometheus.Desc
+	maxBufferBytesBeforeReady *prometheus.Desc
+	jobMaxRunning             *prometheus.Desc
+	jobMaxStandby             *prometheus.Desc
+	configuredTuners          *prometheus.Desc
+	detectedTuners            *prometheus.Desc
+	undetectedTuners          *prometheus.Desc
+	configuredChannels        *prometheus.Desc
+}
+
+// Verify if configExporter implements prometheus.Collector
+var _ prometheus.Collector = (*configExporter)(nil)
+
+func newConfigExporter(ctx context.Context, client *mirakurun.Client, config Config, logger log.Logger) *configExporter {
+	const subsystem = "config"
+
+	return &configExporter{
+		ctx:    ctx,
+		client: client,
+		filter: config.ServiceFilter,
+		logger: logger,
+
+		epgGatheringInterval: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "epg_gathering_interval_seconds"),
+			"Interval of EPG gathering configured in Mirakurun in seconds.",
+			nil, nil),
+		epgRetrievalTime: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "epg_retrieval_time_seconds"),
+			"Time to retrieve EPG configured in Mirakurun in seconds.",
+			nil, nil),
+		programGCInterval: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "program_gc_interval_seconds"),
+			"Interval of garbage collection of programs configured in Mirakurun in seconds.",
+			nil, nil),
+		logoDataInterval: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "logo_data_interval_seconds"),
+			"Interval of logo data gathering configured in Mirakurun in seconds.",
+			nil, nil),
+		eventEndTimeout: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "event_end_timeout_seconds"),
+			"Timeout of event end configured in Mirakurun in seconds.",
+			nil, nil),
+		maxBufferBytesBeforeReady: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "max_buffer_before_ready_bytes"),
+			"Maximum size of the buffer before a stream is ready configured in Mirakurun in bytes.",
+			nil, nil),
+		jobMaxRunning: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "job_max_running"),
+			"Maximum number of running jobs configured in Mirakurun.",
+			nil, nil),
+		jobMaxStandby: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "job_max_standby"),
+			"Maximum number of standby jobs configured in Mirakurun.",
+			nil, nil),
+		configuredTuners: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "configured_tuners"),
+			"Number of tuners configured in Mirakurun.",
+			[]string{"disabled"}, nil),
+		detectedTuners: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "detected_tuners"),
+			"Number of tuner devices detected by Mirakurun.",
+			nil, nil),
+		undetectedTuners: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "undetected_tuners"),
+			"Number of enabled tuners configured in Mirakurun but not detected as tuner devices.",
+			nil, nil),
+		configuredChannels: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "configured_channels"),
+			"Number of channels configured in Mirakurun, where disabled=\"true\" counts disabled channels.",
+			[]string{"channel_type", "disabled"}, nil),
+	}
+}
+
+func (e *configExporter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- e.epgGatheringInterval
+	ch <- e.epgRetrievalTime
+	ch <- e.programGCInterval
+	ch <- e.logoDataInterval
+	ch <- e.eventEndTimeout
+	ch <- e.maxBufferBytesBeforeReady
+	ch <- e.jobMaxRunning
+	ch <- e.jobMaxStandby
+	ch <- e.configuredTuners
+	ch <- e.detectedTuners
+	ch <- e.undetectedTuners
+	ch <- e.configuredChannels
+}
+
+func (e *configExporter) Collect(ch chan<- prometheus.Metric) {
+	e.collectServer(ch)
+	e.collectTuners(ch)
+	e.collectChannels(ch)
+}
+
+func (e *configExporter) collectServer(ch chan<- prometheus.Metric) {
+	server, err := e.client.GetServerConfig(e.ctx)
+	if err != nil {
+		level.Error(e.logger).Log("msg", "failed to fetch Mirakurun server config", "err", err)
+		return
+	}
+
+	// durations are in milliseconds
+	milliseconds := []struct {
+		desc  *prometheus.Desc
+		value *int64
+	}{
+		{e.epgGatheringInterval, server.EPGGatheringInterval},
+		{e.epgRetrievalTime, server.EPGRetrievalTime},
+		{e.programGCInterval, server.ProgramGCInterval},
+		{e.logoDataInterval, server.LogoDataInterval},
+		{e.eventEndTimeout, server.EventEndTimeout},
+	}
+	for _, m := range milliseconds {
+		if m.value != nil {
+			ch <- prometheus.MustNewConstMetric(m.desc, prometheus.GaugeValue, float64(*m.value)/1000)
+		}
+	}
+	if server.MaxBufferBytesBeforeReady != nil {
+		ch <- prometheus.MustNewConstMetric(e.maxBufferBytesBeforeReady, prometheus.GaugeValue, float64(*server.MaxBufferBytesBeforeReady))
+	}
+	if server.JobMaxRunning != nil {
+		ch <- prometheus.MustNewConstMetric(e.jobMaxRunning, prometheus.GaugeValue, float64(*server.JobMaxRunning))
+	}
+	if server.JobMaxStandby != nil {
+		ch <- prometheus.MustNewConstMetric(e.jobMaxStandby, prometheus.GaugeValue, float64(*server.JobMaxStandby))
+	}
+}
+
+func (e *configExporter) collectTuners(ch chan<- prometheus.Metric) {
+	config, err := e.client.GetTunersConfig(e.ctx)
+	if err != nil {
+		level.Error(e.logger).Log("msg", "failed to fetch Mirakurun tuners config", "err", err)
+		return
+	}
+
+	tuners, err := e.client.GetTuners(e.ctx)
+	if err != nil {
+		level.Error(e.logger).Log("msg", "failed to fetch Mirakurun tuners", "err", err)
+		return
+	}
+
+	detected := map[string]bool{}
+	for _, tuner := range *tuners {
+		detected[tuner.Name] = true
+	}
+
+	var enabled, disabled, undetected int
+	for _, tuner := range *config {
+		if tuner.IsDisabled != nil && *tuner.IsDisabled {
+			disabled++
+			continue
+		}
+		enabled++
+		if !detected[tuner.Name] {
+			undetected++
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(e.configuredTuners, prometheus.GaugeValue, float64(enabled), "false")
+	ch <- prometheus.MustNewConstMetric(e.configuredTuners, prometheus.GaugeValue, float64(disabled), "true")
+	ch <- prometheus.MustNewConstMetric(e.detectedTuners, prometheus.GaugeValue, float64(len(*tuners)))
+	ch <- prometheus.MustNewConstMetric(e.undetectedTuners, prometheus.GaugeValue, float64(undetected))
+}
+
+func (e *configExporter) collectChannels(ch chan<- prometheus.Metric) {
+	config, err := e.client.GetChannelsConfig(e.ctx)
+	if err != nil {
+		level.Error(e.logger).Log("msg", "failed to fetch Mirakurun channels config", "err", err)
+		return
+	}
+
+	counts := map[[2]string]int{}
+	for _, channel := range *config {
+		if !e.filter.allowsChannelType(channel.Type) {
+			continue
+		}
+		// report zero for channel types without enabled or disabled channels
+		if _, ok := counts[[2]string{channel.Type, "true"}]; !ok {
+			counts[[2]string{channel.Type, "false"}] = 0
+			counts[[2]string{channel.Type, "true"}] = 0
+		}
+		disabled := channel.IsDisabled != nil && *channel.IsDisabled
+		counts[[2]string{channel.Type, strconv.FormatBool(disabled)}]++
+	}
+
+	for key, count := range counts {
+		ch <- prometheus.MustNewConstMetric(e.configuredChannels, prometheus.GaugeValue, float64(count), key[0], key[1])
+	}
+}
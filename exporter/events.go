@@ -0,0 +1,168 @@
+// Copyright 2021 coord_e
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//  	 http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/coord-e/mirakurun_exporter/mirakurun"
+)
+
+type eventKey struct {
+	resource  string
+	eventType string
+}
+
+type eventTracker struct {
+	mu          sync.Mutex
+	connected   bool
+	connections int
+	counts      map[eventKey]int
+	lastTimes   map[eventKey]time.Time
+}
+
+func newEventTracker() *eventTracker {
+	return &eventTracker{
+		counts:    map[eventKey]int{},
+		lastTimes: map[eventKey]time.Time{},
+	}
+}
+
+func (t *eventTracker) observe(event *mirakurun.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := eventKey{resource: event.Resource, eventType: event.Type}
+	t.counts[key]++
+	// time is in milliseconds
+	t.lastTimes[key] = time.UnixMilli(event.Time)
+}
+
+func (t *eventTracker) setConnected(connected bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if connected {
+		t.connections++
+	}
+	t.connected = connected
+}
+
+type eventSnapshot struct {
+	connected   bool
+	connections int
+	counts      map[eventKey]int
+	lastTimes   map[eventKey]time.Time
+}
+
+func (t *eventTracker) snapshot() eventSnapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := eventSnapshot{
+		connected:   t.connected,
+		connections: t.connections,
+		counts:      make(map[eventKey]int, len(t.counts)),
+		lastTimes:   make(map[eventKey]time.Time, len(t.lastTimes)),
+	}
+	for key, count := range t.counts {
+		s.counts[key] = count
+	}
+	for key, last := range t.lastTimes {
+		s.lastTimes[key] = last
+	}
+	return s
+}
+
+// RunEventSubscriber subscribes to events of Mirakurun and records them in state until ctx is cancelled.
+func RunEventSubscriber(ctx context.Context, client *mirakurun.Client, query *mirakurun.EventsQuery, state *State, logger log.Logger) {
+	subscriber := mirakurun.NewEventSubscriber(client, query)
+	subscriber.OnConnect = func() {
+		level.Info(logger).Log("msg", "connected to Mirakurun event stream")
+		state.events.setConnected(true)
+	}
+	subscriber.OnDisconnect = func(error) {
+		state.events.setConnected(false)
+	}
+
+	err := subscriber.Run(ctx, state.events.observe)
+	level.Info(logger).Log("msg", "stopped subscribing Mirakurun events", "err", err)
+}
+
+type eventsExporter struct {
+	state *State
+
+	connected   *prometheus.Desc
+	connections *prometheus.Desc
+	events      *prometheus.Desc
+	lastEvent   *prometheus.Desc
+}
+
+// Verify if eventsExporter implements prometheus.Collector
+var _ prometheus.Collector = (*eventsExporter)(nil)
+
+func newEventsExporter(state *State) *eventsExporter {
+	const subsystem = "events"
+
+	return &eventsExporter{
+		state: state,
+
+		connected: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "stream_connected"),
+			"Whether the exporter is connected to the event stream of Mirakurun (1) or not (0).",
+			nil, nil),
+		connections: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "stream_connections_total"),
+			"Total number of connections established to the event stream of Mirakurun.",
+			nil, nil),
+		events: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "events_total"),
+			"Total number of events received from Mirakurun.",
+			[]string{"resource", "type"}, nil),
+		lastEvent: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "last_event_timestamp_seconds"),
+			"Unix time of the last event received from Mirakurun.",
+			[]string{"resource", "type"}, nil),
+	}
+}
+
+func (e *eventsExporter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- e.connected
+	ch <- e.connections
+	ch <- e.events
+	ch <- e.lastEvent
+}
+
+func (e *eventsExporter) Collect(ch chan<- prometheus.Metric) {
+	snapshot := e.state.events.snapshot()
+
+	var connected float64
+	if snapshot.connected {
+		connected = 1
+	}
+	ch <- prometheus.MustNewConstMetric(e.connected, prometheus.GaugeValue, connected)
+	ch <- prometheus.MustNewConstMetric(e.connections, prometheus.CounterValue, float64(snapshot.connections))
+	for key, count := range snapshot.counts {
+		ch <- prometheus.MustNewConstMetric(e.events, prometheus.CounterValue, float64(count), key.resource, key.eventType)
+	}
+	for key, last := range snapshot.lastTimes {
+		ch <- prometheus.MustNewConstMetric(e.lastEvent, prometheus.GaugeValue, float64(last.UnixMilli())/1000, key.resource, key.eventType)
+	}
+}
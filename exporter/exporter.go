@@ -15,6 +15,7 @@ package exporter
 
 import (
 	"context"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/prometheus/client_golang/prometheus"
@@ -29,49 +30,121 @@ type Config struct {
 	FetchTuners   bool
 	FetchPrograms bool
 	FetchServices bool
+	FetchChannels bool
+	FetchConfig   bool
+	FetchVersion  bool
+	FetchEvents   bool
+
+	Mirror                   bool
+	MirrorResyncInterval     time.Duration
+	MirrorTunersPollInterval time.Duration
+
+	ServicesEPGStaleThreshold time.Duration
+	ServicesEPGOverdueCycles  int
+	ServiceNameLabel          bool
+	NetworkKinds              []NetworkKindRange
+	ProgramsWindows           []ProgramsWindow
+	ProgramsScheduleWindow    time.Duration
+	ProgramsCurrentInfo       bool
+	ProgramsGenreAggregate    bool
+
+	ProgramsSeriesExpiryThreshold time.Duration
+	ProgramsServiceIDs            []int
+
+	ServiceFilter ServiceFilter
+
+	LabelLimit     int
+	LabelMaxLength int
 }
 
 type Exporter struct {
 	ctx    context.Context
+	state  *State
 	logger log.Logger
 
+	labelOverflows *prometheus.Desc
+
 	status   *statusExporter
 	tuners   *tunersExporter
 	programs *programsExporter
 	services *servicesExporter
+	channels *channelsExporter
+	config   *configExporter
+	version  *versionExporter
+	events   *eventsExporter
+	mirror   *mirrorExporter
 }
 
 // Verify if Exporter implements prometheus.Collector
 var _ prometheus.Collector = (*Exporter)(nil)
 
-func New(ctx context.Context, client *mirakurun.Client, config Config, logger log.Logger) *Exporter {
+func New(ctx context.Context, client *mirakurun.Client, config Config, state *State, logger log.Logger) *Exporter {
 	var statusExporter *statusExporter
 	if config.FetchStatus {
-		statusExporter = newStatusExporter(ctx, client, logger)
+		statusExporter = newStatusExporter(ctx, client, state, logger)
 	}
 
 	var tunersExporter *tunersExporter
 	if config.FetchTuners {
-		tunersExporter = newTunersExporter(ctx, client, logger)
+		tunersExporter = newTunersExporter(ctx, client, config, state, logger)
 	}
 
 	var programsExporter *programsExporter
 	if config.FetchPrograms {
-		programsExporter = newProgramsExporter(ctx, client, logger)
+		programsExporter = newProgramsExporter(ctx, client, config, state, logger)
 	}
 
 	var servicesExporter *servicesExporter
 	if config.FetchServices {
-		servicesExporter = newServicesExporter(ctx, client, logger)
+		servicesExporter = newServicesExporter(ctx, client, config, state, logger)
+	}
+
+	var channelsExporter *channelsExporter
+	if config.FetchChannels {
+		channelsExporter = newChannelsExporter(ctx, client, logger)
+	}
+
+	var configExporter *configExporter
+	if config.FetchConfig {
+		configExporter = newConfigExporter(ctx, client, config, logger)
+	}
+
+	var versionExporter *versionExporter
+	if config.FetchVersion {
+		versionExporter = newVersionExporter(client, state, logger)
+	}
+
+	// events are received by RunEventSubscriber in background
+	var eventsExporter *eventsExporter
+	if config.FetchEvents {
+		eventsExporter = newEventsExporter(state)
+	}
+
+	// the mirror is maintained by RunMirror in background
+	var mirrorExporter *mirrorExporter
+	if config.Mirror {
+		mirrorExporter = newMirrorExporter(state)
 	}
 
 	return &Exporter{
-		ctx:      ctx,
-		logger:   logger,
+		ctx:    ctx,
+		state:  state,
+		logger: logger,
+
+		labelOverflows: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "exporter", "label_overflow_total"),
+			"Total number of distinct label values replaced with "+overflowLabelValue+" or dropped due to the limit of distinct values, labeled by metric name or "+serviceNameLimitKey+" for service names.",
+			[]string{"metric"}, nil),
+
 		status:   statusExporter,
 		tuners:   tunersExporter,
 		programs: programsExporter,
 		services: servicesExporter,
+		channels: channelsExporter,
+		config:   configExporter,
+		version:  versionExporter,
+		events:   eventsExporter,
+		mirror:   mirrorExporter,
 	}
 }
 
@@ -88,6 +161,22 @@ func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 	if e.services != nil {
 		e.services.Describe(ch)
 	}
+	if e.channels != nil {
+		e.channels.Describe(ch)
+	}
+	if e.config != nil {
+		e.config.Describe(ch)
+	}
+	if e.version != nil {
+		e.version.Describe(ch)
+	}
+	if e.events != nil {
+		e.events.Describe(ch)
+	}
+	if e.mirror != nil {
+		e.mirror.Describe(ch)
+	}
+	ch <- e.labelOverflows
 }
 
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
@@ -103,4 +192,22 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 	if e.services != nil {
 		e.services.Collect(ch)
 	}
+	if e.channels != nil {
+		e.channels.Collect(ch)
+	}
+	if e.config != nil {
+		e.config.Collect(ch)
+	}
+	if e.version != nil {
+		e.version.Collect(ch)
+	}
+	if e.events != nil {
+		e.events.Collect(ch)
+	}
+	if e.mirror != nil {
+		e.mirror.Collect(ch)
+	}
+	for metric, count := range e.state.labels.overflowCounts() {
+		ch <- prometheus.MustNewConstMetric(e.labelOverflows, prometheus.CounterValue, float64(count), metric)
+	}
 }
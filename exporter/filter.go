@@ -0,0 +1,147 @@
+// Copyright 2021 coord_e
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//  	 http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/coord-e/mirakurun_exporter/mirakurun"
+)
+
+// ServiceMatcher matches services by one of their attributes.
+type ServiceMatcher struct {
+	field string
+	value string
+	re    *regexp.Regexp
+}
+
+// ParseServiceMatcher parses a string in the form of "FIELD=VALUE",
+// where FIELD is one of service_id, network_id, channel_type, service_type and name.
+// service_type accepts either a number or a kind such as "tv", and name accepts a regular expression.
+func ParseServiceMatcher(s string) (ServiceMatcher, error) {
+	field, value, ok := strings.Cut(s, "=")
+	if !ok {
+		return ServiceMatcher{}, fmt.Errorf("missing value in %q", s)
+	}
+
+	m := ServiceMatcher{field: field, value: value}
+	switch field {
+	case "service_id", "network_id":
+		if _, err := strconv.Atoi(value); err != nil {
+			return ServiceMatcher{}, fmt.Errorf("invalid %s in %q: %w", field, s, err)
+		}
+	case "channel_type", "service_type":
+	case "name":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return ServiceMatcher{}, fmt.Errorf("invalid regular expression in %q: %w", s, err)
+		}
+		m.re = re
+	default:
+		return ServiceMatcher{}, fmt.Errorf("unknown field %q in %q", field, s)
+	}
+	return m, nil
+}
+
+// serviceAttributes holds attributes of a service to be matched.
+// Zero values represent unknown attributes, which never match.
+type serviceAttributes struct {
+	serviceID   int
+	networkID   int
+	channelType string
+	serviceType int
+	name        string
+}
+
+func (m ServiceMatcher) match(attrs serviceAttributes) bool {
+	switch m.field {
+	case "service_id":
+		return attrs.serviceID != 0 && strconv.Itoa(attrs.serviceID) == m.value
+	case "network_id":
+		return attrs.networkID != 0 && strconv.Itoa(attrs.networkID) == m.value
+	case "channel_type":
+		return len(attrs.channelType) != 0 && attrs.channelType == m.value
+	case "service_type":
+		return attrs.serviceType != 0 && (strconv.Itoa(attrs.serviceType) == m.value || serviceKind(attrs.serviceType) == m.value)
+	case "name":
+		return len(attrs.name) != 0 && m.re.MatchString(attrs.name)
+	default:
+		return false
+	}
+}
+
+// ServiceFilter selects services to export metrics of.
+// A service is selected if it matches any of Include (or Include is empty) and none of Exclude.
+type ServiceFilter struct {
+	Include []ServiceMatcher
+	Exclude []ServiceMatcher
+}
+
+func (f ServiceFilter) empty() bool {
+	return len(f.Include) == 0 && len(f.Exclude) == 0
+}
+
+func (f ServiceFilter) allows(attrs serviceAttributes) bool {
+	for _, m := range f.Exclude {
+		if m.match(attrs) {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, m := range f.Include {
+		if m.match(attrs) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsChannelType reports whether a channel of the type may carry services selected by the filter.
+// Only rules on channel_type are decisive, as other attributes belong to services.
+func (f ServiceFilter) allowsChannelType(channelType string) bool {
+	attrs := serviceAttributes{channelType: channelType}
+	for _, m := range f.Exclude {
+		if m.field == "channel_type" && m.match(attrs) {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, m := range f.Include {
+		if m.field != "channel_type" || m.match(attrs) {
+			return true
+		}
+	}
+	return false
+}
+
+// serviceAttributesOf extracts attributes to be matched from a service.
+func serviceAttributesOf(service *mirakurun.Service) serviceAttributes {
+	attrs := serviceAttributes{
+		serviceID:   service.ServiceID,
+		networkID:   service.NetworkID,
+		serviceType: service.Type,
+		name:        service.Name,
+	}
+	if service.Channel != nil {
+		attrs.channelType = service.Channel.Type
+	}
+	return attrs
+}
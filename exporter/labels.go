@@ -0,0 +1,175 @@
+// Copyright 2021 coord_e
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//  	 http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// overflowLabelValue replaces label values beyond the limit of distinct values.
+const overflowLabelValue = "__other__"
+
+// serviceNameLimitKey is used in place of a metric name to limit service names,
+// which are shared by per-service metrics of services and programs and thus limited together.
+const serviceNameLimitKey = "service_name"
+
+// labelValueExpiry is the duration after which an admitted label value not seen anymore gives its place to new values.
+// Rejected values not seen for this duration are forgotten as well, and counted again as overflows when seen later.
+const labelValueExpiry = time.Hour
+
+// labelSweepInterval is the minimum interval between sweeps of expired values of a metric.
+const labelSweepInterval = time.Minute
+
+// labelLimiter bounds the number of distinct values of a label per metric.
+// Values are admitted in the order they are first seen and kept while they keep being seen,
+// so that series do not flap between scrapes. Values not seen for labelValueExpiry are evicted
+// to make room for new ones, as some values such as program names change over time.
+type labelLimiter struct {
+	maxValues int
+	maxLength int
+	now       func() time.Time
+
+	mu     sync.Mutex
+	labels map[string]*limitedLabel
+}
+
+// limitedLabel holds values of a label of a metric along with the time they were last seen.
+type limitedLabel struct {
+	values    map[string]time.Time
+	rejected  map[string]time.Time
+	sweptAt   time.Time
+	overflows int
+}
+
+// newLabelLimiter creates a labelLimiter. Zero maxValues or maxLength means unlimited.
+func newLabelLimiter(maxValues, maxLength int) *labelLimiter {
+	return &labelLimiter{
+		maxValues: maxValues,
+		maxLength: maxLength,
+		now:       time.Now,
+		labels:    map[string]*limitedLabel{},
+	}
+}
+
+// limit sanitizes value and returns it if admitted for metric, or overflowLabelValue otherwise.
+func (l *labelLimiter) limit(metric, value string) string {
+	value = l.sanitize(value)
+	if l.maxValues <= 0 {
+		return value
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	label, ok := l.labels[metric]
+	if !ok {
+		label = &limitedLabel{values: map[string]time.Time{}, rejected: map[string]time.Time{}}
+		l.labels[metric] = label
+	}
+	if _, ok := label.values[value]; ok {
+		label.values[value] = now
+		return value
+	}
+	if len(label.values) >= l.maxValues && now.Sub(label.sweptAt) >= labelSweepInterval {
+		label.sweep(now)
+	}
+	if len(label.values) >= l.maxValues {
+		// count each rejected value once, not on every scrape
+		if _, ok := label.rejected[value]; !ok {
+			label.overflows++
+		}
+		label.rejected[value] = now
+		return overflowLabelValue
+	}
+	delete(label.rejected, value)
+	label.values[value] = now
+	return value
+}
+
+func (label *limitedLabel) sweep(now time.Time) {
+	for _, values := range []map[string]time.Time{label.values, label.rejected} {
+		for v, lastSeen := range values {
+			if now.Sub(lastSeen) >= labelValueExpiry {
+				delete(values, v)
+			}
+		}
+	}
+	label.sweptAt = now
+}
+
+// limitCounts limits keys of counts for metric, summing up counts of keys bucketed together.
+func (l *labelLimiter) limitCounts(metric string, counts map[string]int64) map[string]int64 {
+	limited := make(map[string]int64, len(counts))
+	for _, key := range sortedKeys(counts) {
+		limited[l.limit(metric, key)] += counts[key]
+	}
+	return limited
+}
+
+// limitCounters limits keys of counters for metric, dropping keys not admitted instead of summing them up,
+// as the sum over the changing set of keys bucketed together would not be monotonic.
+func (l *labelLimiter) limitCounters(metric string, counters map[string]int64) map[string]int64 {
+	limited := make(map[string]int64, len(counters))
+	for _, key := range sortedKeys(counters) {
+		if limitedKey := l.limit(metric, key); limitedKey != overflowLabelValue {
+			limited[limitedKey] = counters[key]
+		}
+	}
+	return limited
+}
+
+// sortedKeys returns keys of m in sorted order so that admission does not depend on map iteration order.
+func sortedKeys(m map[string]int64) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (l *labelLimiter) sanitize(value string) string {
+	value = strings.ToValidUTF8(value, string(unicode.ReplacementChar))
+	value = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, value)
+	if l.maxLength > 0 {
+		if runes := []rune(value); len(runes) > l.maxLength {
+			value = string(runes[:l.maxLength])
+		}
+	}
+	return value
+}
+
+// overflowCounts returns a snapshot of the number of distinct values rejected per metric.
+func (l *labelLimiter) overflowCounts() map[string]int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	counts := make(map[string]int, len(l.labels))
+	for metric, label := range l.labels {
+		if label.overflows > 0 {
+			counts[metric] = label.overflows
+		}
+	}
+	return counts
+}
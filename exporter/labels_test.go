@@ -0,0 +1,167 @@
+// Copyright 2021 coord_e
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//  	 http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLabelLimiter(t *testing.T) {
+	type step struct {
+		after time.Duration
+		value string
+		want  string
+	}
+	tests := []struct {
+		name          string
+		maxValues     int
+		steps         []step
+		wantKept      int
+		wantOverflows int
+	}{
+		{
+			name:      "unlimited",
+			maxValues: 0,
+			steps: []step{
+				{value: "a", want: "a"},
+				{value: "b", want: "b"},
+			},
+			wantKept: 0,
+		},
+		{
+			name:      "overflow",
+			maxValues: 2,
+			steps: []step{
+				{value: "a", want: "a"},
+				{value: "b", want: "b"},
+				{value: "c", want: overflowLabelValue},
+				{value: "a", want: "a"},
+			},
+			wantKept:      2,
+			wantOverflows: 1,
+		},
+		{
+			name:      "rejected values are counted once",
+			maxValues: 1,
+			steps: []step{
+				{value: "a", want: "a"},
+				{value: "b", want: overflowLabelValue},
+				{after: labelValueExpiry / 2, value: "a", want: "a"},
+				{value: "b", want: overflowLabelValue},
+				{value: "c", want: overflowLabelValue},
+			},
+			wantKept:      1,
+			wantOverflows: 2,
+		},
+		{
+			name:      "expired rejected values are counted again",
+			maxValues: 1,
+			steps: []step{
+				{value: "a", want: "a"},
+				{value: "b", want: overflowLabelValue},
+				{after: labelValueExpiry / 2, value: "a", want: "a"},
+				{after: labelValueExpiry / 2, value: "a", want: "a"},
+				{value: "b", want: overflowLabelValue},
+			},
+			wantKept:      1,
+			wantOverflows: 2,
+		},
+		{
+			name:      "expired values are evicted",
+			maxValues: 2,
+			steps: []step{
+				{value: "a", want: "a"},
+				{value: "b", want: "b"},
+				{after: labelValueExpiry / 2, value: "b", want: "b"},
+				{after: labelValueExpiry / 2, value: "c", want: "c"},
+				{value: "a", want: overflowLabelValue},
+				{value: "b", want: "b"},
+			},
+			wantKept:      2,
+			wantOverflows: 1,
+		},
+		{
+			name:      "values seen recently are kept",
+			maxValues: 1,
+			steps: []step{
+				{value: "a", want: "a"},
+				{after: labelValueExpiry / 2, value: "a", want: "a"},
+				{after: labelValueExpiry / 2, value: "b", want: overflowLabelValue},
+			},
+			wantKept:      1,
+			wantOverflows: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		now := time.Unix(0, 0)
+		l := newLabelLimiter(tt.maxValues, 0)
+		l.now = func() time.Time { return now }
+		for i, s := range tt.steps {
+			now = now.Add(s.after)
+			if got := l.limit("m", s.value); got != s.want {
+				t.Errorf("%s: step %d: limit(%q) = %q, want %q", tt.name, i, s.value, got, s.want)
+			}
+		}
+		var kept int
+		if label, ok := l.labels["m"]; ok {
+			kept = len(label.values)
+		}
+		if kept != tt.wantKept {
+			t.Errorf("%s: %d values kept, want %d", tt.name, kept, tt.wantKept)
+		}
+		if got := l.overflowCounts()["m"]; got != tt.wantOverflows {
+			t.Errorf("%s: %d overflows, want %d", tt.name, got, tt.wantOverflows)
+		}
+	}
+}
+
+func TestLabelLimiterCounts(t *testing.T) {
+	counts := map[string]int64{"c": 3, "a": 1, "b": 2}
+
+	l := newLabelLimiter(2, 0)
+	gotCounts := l.limitCounts("counts", counts)
+	wantCounts := map[string]int64{"a": 1, "b": 2, overflowLabelValue: 3}
+	if !reflect.DeepEqual(gotCounts, wantCounts) {
+		t.Errorf("limitCounts() = %v, want %v", gotCounts, wantCounts)
+	}
+
+	gotCounters := l.limitCounters("counters", counts)
+	wantCounters := map[string]int64{"a": 1, "b": 2}
+	if !reflect.DeepEqual(gotCounters, wantCounters) {
+		t.Errorf("limitCounters() = %v, want %v", gotCounters, wantCounters)
+	}
+}
+
+func TestLabelLimiterSanitize(t *testing.T) {
+	tests := []struct {
+		maxLength int
+		value     string
+		want      string
+	}{
+		{maxLength: 0, value: "abc", want: "abc"},
+		{maxLength: 2, value: "abc", want: "ab"},
+		{maxLength: 2, value: "日本語", want: "日本"},
+		{maxLength: 0, value: "a\nb\tc", want: "a b c"},
+		{maxLength: 0, value: "a\xffb", want: "a�b"},
+	}
+
+	for _, tt := range tests {
+		if got := newLabelLimiter(0, tt.maxLength).sanitize(tt.value); got != tt.want {
+			t.Errorf("sanitize(%q) with max length %d = %q, want %q", tt.value, tt.maxLength, got, tt.want)
+		}
+	}
+}
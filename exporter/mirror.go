@@ -0,0 +1,525 @@
+// Copyright 2021 coord_e
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//  	 http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/coord-e/mirakurun_exporter/mirakurun"
+)
+
+const (
+	mirrorResourceProgram = "program"
+	mirrorResourceService = "service"
+	mirrorResourceTuner   = "tuner"
+)
+
+// mirrorMinResyncInterval is the minimum interval between resyncs triggered by reconnections to the event stream.
+// A reconnection within the interval after the last resync defers the resync instead.
+const mirrorMinResyncInterval = time.Minute
+
+// Failed resyncs are retried with exponential backoff between mirrorMinRetryBackoff and mirrorMaxRetryBackoff.
+const (
+	mirrorMinRetryBackoff = 10 * time.Second
+	mirrorMaxRetryBackoff = 10 * time.Minute
+)
+
+// source provides resources of Mirakurun either from the API or from the mirror.
+type source interface {
+	GetPrograms(ctx context.Context, query *mirakurun.ProgramsQuery) (*mirakurun.ProgramsResponse, error)
+	GetServices(ctx context.Context) (*mirakurun.ServicesResponse, error)
+	GetTuners(ctx context.Context) (*mirakurun.TunersResponse, error)
+}
+
+// Verify if mirakurun.Client implements source
+var _ source = (*mirakurun.Client)(nil)
+
+// source returns the mirror if enabled, or client otherwise.
+func (s *State) source(client *mirakurun.Client) source {
+	if s.mirror == nil {
+		return client
+	}
+	return &mirrorSource{mirror: s.mirror, client: client}
+}
+
+// mirror is an in-memory copy of programs, services and tuners of Mirakurun kept up to date by events.
+// Stream counters of tuners change without events, so tuners are also polled every tuners poll interval.
+type mirror struct {
+	resyncInterval     time.Duration
+	tunersPollInterval time.Duration
+
+	mu       sync.RWMutex
+	ready    bool
+	programs map[int64]mirakurun.Program
+	services map[int64]mirakurun.Service
+	tuners   map[int]mirakurun.Tuner
+
+	resyncs    int
+	lastResync time.Time
+	divergence map[string]int
+}
+
+func newMirror(resyncInterval, tunersPollInterval time.Duration) *mirror {
+	return &mirror{
+		resyncInterval:     resyncInterval,
+		tunersPollInterval: tunersPollInterval,
+		programs:           map[int64]mirakurun.Program{},
+		services:           map[int64]mirakurun.Service{},
+		tuners:             map[int]mirakurun.Tuner{},
+		divergence:         map[string]int{},
+	}
+}
+
+// RunMirror keeps the mirror in state up to date until ctx is cancelled.
+// The mirror is fully loaded on connections to the event stream and every resync interval, and events are applied in between.
+// Resyncs on connections are rate limited by mirrorMinResyncInterval, and zero resync interval disables periodic resyncs.
+// Failed resyncs are retried with backoff, so that the mirror gets ready even if Mirakurun is not available at first.
+// Tuners are polled every tuners poll interval in addition, and zero tuners poll interval disables polling.
+// Received events are also recorded in state as RunEventSubscriber does.
+func RunMirror(ctx context.Context, client *mirakurun.Client, state *State, logger log.Logger) {
+	m := state.mirror
+
+	// nil is sent on connection to request a resync in order with events
+	events := make(chan *mirakurun.Event, 1024)
+	send := func(event *mirakurun.Event) {
+		select {
+		case events <- event:
+		case <-ctx.Done():
+		}
+	}
+
+	subscriber := mirakurun.NewEventSubscriber(client, nil)
+	subscriber.OnConnect = func() {
+		level.Info(logger).Log("msg", "connected to Mirakurun event stream")
+		state.events.setConnected(true)
+		send(nil)
+	}
+	subscriber.OnDisconnect = func(error) {
+		state.events.setConnected(false)
+	}
+	go func() {
+		err := subscriber.Run(ctx, func(event *mirakurun.Event) {
+			state.events.observe(event)
+			send(event)
+		})
+		level.Info(logger).Log("msg", "stopped subscribing Mirakurun events", "err", err)
+	}()
+
+	// periodic and deferred resyncs and polling tuners are disabled while their channels are nil
+	var periodic, deferred, tunersPoll <-chan time.Time
+	if m.resyncInterval > 0 {
+		ticker := time.NewTicker(m.resyncInterval)
+		defer ticker.Stop()
+		periodic = ticker.C
+	}
+	if m.tunersPollInterval > 0 {
+		ticker := time.NewTicker(m.tunersPollInterval)
+		defer ticker.Stop()
+		tunersPoll = ticker.C
+	}
+
+	var lastResync time.Time
+	backoff := mirrorMinRetryBackoff
+	resync := func() {
+		lastResync = time.Now()
+		deferred = nil
+		if err := m.resync(ctx, client); err != nil {
+			level.Error(logger).Log("msg", "failed to resync mirror", "err", err, "retry_in", backoff)
+			deferred = time.After(backoff)
+			backoff *= 2
+			if backoff > mirrorMaxRetryBackoff {
+				backoff = mirrorMaxRetryBackoff
+			}
+			return
+		}
+		backoff = mirrorMinRetryBackoff
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-periodic:
+			resync()
+		case <-deferred:
+			resync()
+		case <-tunersPoll:
+			if err := m.pollTuners(ctx, client); err != nil {
+				level.Error(logger).Log("msg", "failed to fetch Mirakurun tuners for mirror", "err", err)
+			}
+		case event := <-events:
+			if event != nil {
+				if err := m.apply(event); err != nil {
+					level.Warn(logger).Log("msg", "failed to apply Mirakurun event to mirror", "resource", event.Resource, "type", event.Type, "err", err)
+				}
+				continue
+			}
+			// events may have been missed while disconnected
+			wait := mirrorMinResyncInterval - time.Since(lastResync)
+			switch {
+			case lastResync.IsZero() || wait <= 0:
+				resync()
+			case deferred == nil:
+				level.Info(logger).Log("msg", "deferring mirror resync after reconnection", "wait", wait)
+				deferred = time.After(wait)
+			}
+		}
+	}
+}
+
+// resync replaces the mirror with resources fetched from Mirakurun and records how much the mirror diverged from them.
+// Events received while fetching are applied afterwards, which is harmless as they are idempotent.
+func (m *mirror) resync(ctx context.Context, client *mirakurun.Client) error {
+	programs, err := client.GetPrograms(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to fetch Mirakurun programs: %w", err)
+	}
+	services, err := client.GetServices(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch Mirakurun services: %w", err)
+	}
+	tuners, err := client.GetTuners(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch Mirakurun tuners: %w", err)
+	}
+
+	newPrograms := make(map[int64]mirakurun.Program, len(*programs))
+	for _, program := range *programs {
+		newPrograms[program.ID] = program
+	}
+	newServices := make(map[int64]mirakurun.Service, len(*services))
+	for _, service := range *services {
+		newServices[service.ID] = service
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// tuners are left out as their stream counters always diverge
+	if m.ready {
+		m.divergence[mirrorResourceProgram] = divergence(m.programs, newPrograms)
+		m.divergence[mirrorResourceService] = divergence(m.services, newServices)
+	}
+	m.programs = newPrograms
+	m.services = newServices
+	m.tuners = tunersByIndex(tuners)
+	m.ready = true
+	m.resyncs++
+	m.lastResync = time.Now()
+	return nil
+}
+
+// pollTuners replaces tuners in the mirror with ones fetched from Mirakurun to update their stream counters.
+func (m *mirror) pollTuners(ctx context.Context, client *mirakurun.Client) error {
+	tuners, err := client.GetTuners(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tuners = tunersByIndex(tuners)
+	return nil
+}
+
+func tunersByIndex(tuners *mirakurun.TunersResponse) map[int]mirakurun.Tuner {
+	byIndex := make(map[int]mirakurun.Tuner, len(*tuners))
+	for _, tuner := range *tuners {
+		byIndex[tuner.Index] = tuner
+	}
+	return byIndex
+}
+
+// divergence counts items missing in either of the maps or differing between them.
+func divergence[K comparable, V any](mirrored, fetched map[K]V) int {
+	count := 0
+	for key, item := range fetched {
+		mirroredItem, ok := mirrored[key]
+		if !ok || !reflect.DeepEqual(mirroredItem, item) {
+			count++
+		}
+	}
+	for key := range mirrored {
+		if _, ok := fetched[key]; !ok {
+			count++
+		}
+	}
+	return count
+}
+
+// apply applies an event to the mirror. Events of unknown resources or types result in an error.
+func (m *mirror) apply(event *mirakurun.Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch event.Resource {
+	case mirrorResourceProgram:
+		if event.Type == "redefine" {
+			// the program is redefined with another ID, which is created or updated by another event
+			var redefinition struct {
+				From int64 `json:"from"`
+				To   int64 `json:"to"`
+			}
+			if err := json.Unmarshal(event.Data, &redefinition); err != nil {
+				return err
+			}
+			delete(m.programs, redefinition.From)
+			return nil
+		}
+		return applyItem(m.programs, event, func(program *mirakurun.Program) int64 { return program.ID })
+	case mirrorResourceService:
+		return applyItem(m.services, event, func(service *mirakurun.Service) int64 { return service.ID })
+	case mirrorResourceTuner:
+		return m.applyTuner(event)
+	default:
+		return fmt.Errorf("unknown resource %q", event.Resource)
+	}
+}
+
+// applyTuner applies an event of a tuner, which is identified by its index rather than id.
+func (m *mirror) applyTuner(event *mirakurun.Event) error {
+	switch event.Type {
+	case "create", "update", "remove":
+	default:
+		return fmt.Errorf("unknown event type %q", event.Type)
+	}
+	// index 0 is valid, so the presence of the index is checked separately
+	var index struct {
+		Index *int `json:"index"`
+	}
+	if err := json.Unmarshal(event.Data, &index); err != nil {
+		return err
+	}
+	if index.Index == nil {
+		return fmt.Errorf("missing index in %s event", event.Type)
+	}
+	var tuner mirakurun.Tuner
+	if err := json.Unmarshal(event.Data, &tuner); err != nil {
+		return err
+	}
+
+	if event.Type == "remove" {
+		delete(m.tuners, tuner.Index)
+	} else {
+		m.tuners[tuner.Index] = tuner
+	}
+	return nil
+}
+
+// applyItem applies a create, update or remove event of an item identified by id.
+func applyItem[T any](items map[int64]T, event *mirakurun.Event, id func(*T) int64) error {
+	var item T
+	switch event.Type {
+	case "create", "update", "remove":
+	default:
+		return fmt.Errorf("unknown event type %q", event.Type)
+	}
+	if err := json.Unmarshal(event.Data, &item); err != nil {
+		return err
+	}
+	if id(&item) == 0 {
+		return fmt.Errorf("missing id in %s event", event.Type)
+	}
+
+	if event.Type == "remove" {
+		delete(items, id(&item))
+	} else {
+		items[id(&item)] = item
+	}
+	return nil
+}
+
+// isReady reports whether the mirror has been loaded. Once loaded, the mirror stays ready.
+func (m *mirror) isReady() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.ready
+}
+
+type mirrorSnapshot struct {
+	ready      bool
+	items      map[string]int
+	resyncs    int
+	lastResync time.Time
+	divergence map[string]int
+}
+
+func (m *mirror) snapshot() mirrorSnapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	s := mirrorSnapshot{
+		ready: m.ready,
+		items: map[string]int{
+			mirrorResourceProgram: len(m.programs),
+			mirrorResourceService: len(m.services),
+			mirrorResourceTuner:   len(m.tuners),
+		},
+		resyncs:    m.resyncs,
+		lastResync: m.lastResync,
+		divergence: make(map[string]int, len(m.divergence)),
+	}
+	for resource, count := range m.divergence {
+		s.divergence[resource] = count
+	}
+	return s
+}
+
+// mirrorSource serves resources from the mirror, falling back to client until the mirror is loaded.
+type mirrorSource struct {
+	mirror *mirror
+	client *mirakurun.Client
+}
+
+func (s *mirrorSource) GetPrograms(ctx context.Context, query *mirakurun.ProgramsQuery) (*mirakurun.ProgramsResponse, error) {
+	if !s.mirror.isReady() {
+		return s.client.GetPrograms(ctx, query)
+	}
+
+	s.mirror.mu.RLock()
+	defer s.mirror.mu.RUnlock()
+
+	programs := make(mirakurun.ProgramsResponse, 0, len(s.mirror.programs))
+	for _, program := range s.mirror.programs {
+		if query != nil {
+			if query.NetworkID != nil && program.NetworkID != *query.NetworkID {
+				continue
+			}
+			if query.ServiceID != nil && program.ServiceID != *query.ServiceID {
+				continue
+			}
+			if query.EventID != nil && program.EventID != *query.EventID {
+				continue
+			}
+		}
+		programs = append(programs, program)
+	}
+	sort.Slice(programs, func(i, j int) bool { return programs[i].ID < programs[j].ID })
+	return &programs, nil
+}
+
+func (s *mirrorSource) GetServices(ctx context.Context) (*mirakurun.ServicesResponse, error) {
+	if !s.mirror.isReady() {
+		return s.client.GetServices(ctx)
+	}
+
+	s.mirror.mu.RLock()
+	defer s.mirror.mu.RUnlock()
+
+	services := make(mirakurun.ServicesResponse, 0, len(s.mirror.services))
+	for _, service := range s.mirror.services {
+		services = append(services, service)
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].ID < services[j].ID })
+	return &services, nil
+}
+
+func (s *mirrorSource) GetTuners(ctx context.Context) (*mirakurun.TunersResponse, error) {
+	if !s.mirror.isReady() {
+		return s.client.GetTuners(ctx)
+	}
+
+	s.mirror.mu.RLock()
+	defer s.mirror.mu.RUnlock()
+
+	tuners := make(mirakurun.TunersResponse, 0, len(s.mirror.tuners))
+	for _, tuner := range s.mirror.tuners {
+		tuners = append(tuners, tuner)
+	}
+	sort.Slice(tuners, func(i, j int) bool { return tuners[i].Index < tuners[j].Index })
+	return &tuners, nil
+}
+
+type mirrorExporter struct {
+	state *State
+
+	ready      *prometheus.Desc
+	items      *prometheus.Desc
+	divergence *prometheus.Desc
+	resyncs    *prometheus.Desc
+	lastResync *prometheus.Desc
+}
+
+// Verify if mirrorExporter implements prometheus.Collector
+var _ prometheus.Collector = (*mirrorExporter)(nil)
+
+func newMirrorExporter(state *State) *mirrorExporter {
+	const subsystem = "mirror"
+
+	return &mirrorExporter{
+		state: state,
+
+		ready: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "ready"),
+			"Whether the in-memory mirror of Mirakurun is loaded (1) or not (0).",
+			nil, nil),
+		items: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "items"),
+			"Number of items in the in-memory mirror of Mirakurun.",
+			[]string{"resource"}, nil),
+		divergence: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "divergent_items"),
+			"Number of items which differed between the in-memory mirror and Mirakurun at the last resync. Tuners are not compared.",
+			[]string{"resource"}, nil),
+		resyncs: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "resyncs_total"),
+			"Total number of full resyncs of the in-memory mirror of Mirakurun.",
+			nil, nil),
+		lastResync: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "last_resync_timestamp_seconds"),
+			"Unix time of the last full resync of the in-memory mirror of Mirakurun.",
+			nil, nil),
+	}
+}
+
+func (e *mirrorExporter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- e.ready
+	ch <- e.items
+	ch <- e.divergence
+	ch <- e.resyncs
+	ch <- e.lastResync
+}
+
+func (e *mirrorExporter) Collect(ch chan<- prometheus.Metric) {
+	snapshot := e.state.mirror.snapshot()
+
+	var ready float64
+	if snapshot.ready {
+		ready = 1
+	}
+	ch <- prometheus.MustNewConstMetric(e.ready, prometheus.GaugeValue, ready)
+	ch <- prometheus.MustNewConstMetric(e.resyncs, prometheus.CounterValue, float64(snapshot.resyncs))
+	if !snapshot.ready {
+		return
+	}
+
+	for resource, count := range snapshot.items {
+		ch <- prometheus.MustNewConstMetric(e.items, prometheus.GaugeValue, float64(count), resource)
+	}
+	for resource, count := range snapshot.divergence {
+		ch <- prometheus.MustNewConstMetric(e.divergence, prometheus.GaugeValue, float64(count), resource)
+	}
+	ch <- prometheus.MustNewConstMetric(e.lastResync, prometheus.GaugeValue, float64(snapshot.lastResync.UnixMilli())/1000)
+}
@@ -0,0 +1,213 @@
+// Copyright 2021 coord_e
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//  	 http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/coord-e/mirakurun_exporter/mirakurun"
+)
+
+func TestMirrorApply(t *testing.T) {
+	tests := []struct {
+		name         string
+		event        mirakurun.Event
+		wantErr      bool
+		wantPrograms []int64
+		wantServices []int64
+		wantTuners   []int64
+	}{
+		{
+			name:         "create program",
+			event:        mirakurun.Event{Resource: "program", Type: "create", Data: []byte(`{"id":3,"name":"new"}`)},
+			wantPrograms: []int64{1, 2, 3},
+			wantServices: []int64{10},
+			wantTuners:   []int64{0},
+		},
+		{
+			name:         "update program",
+			event:        mirakurun.Event{Resource: "program", Type: "update", Data: []byte(`{"id":1,"name":"updated"}`)},
+			wantPrograms: []int64{1, 2},
+			wantServices: []int64{10},
+			wantTuners:   []int64{0},
+		},
+		{
+			name:         "remove program",
+			event:        mirakurun.Event{Resource: "program", Type: "remove", Data: []byte(`{"id":1}`)},
+			wantPrograms: []int64{2},
+			wantServices: []int64{10},
+			wantTuners:   []int64{0},
+		},
+		{
+			name:         "remove unknown program",
+			event:        mirakurun.Event{Resource: "program", Type: "remove", Data: []byte(`{"id":99}`)},
+			wantPrograms: []int64{1, 2},
+			wantServices: []int64{10},
+			wantTuners:   []int64{0},
+		},
+		{
+			name:         "redefine program",
+			event:        mirakurun.Event{Resource: "program", Type: "redefine", Data: []byte(`{"from":1,"to":4}`)},
+			wantPrograms: []int64{2},
+			wantServices: []int64{10},
+			wantTuners:   []int64{0},
+		},
+		{
+			name:         "create service",
+			event:        mirakurun.Event{Resource: "service", Type: "create", Data: []byte(`{"id":20,"serviceId":2}`)},
+			wantPrograms: []int64{1, 2},
+			wantServices: []int64{10, 20},
+			wantTuners:   []int64{0},
+		},
+		{
+			name:         "remove service",
+			event:        mirakurun.Event{Resource: "service", Type: "remove", Data: []byte(`{"id":10}`)},
+			wantPrograms: []int64{1, 2},
+			wantServices: []int64{},
+			wantTuners:   []int64{0},
+		},
+		{
+			name:         "update tuner",
+			event:        mirakurun.Event{Resource: "tuner", Type: "update", Data: []byte(`{"index":1,"name":"updated"}`)},
+			wantPrograms: []int64{1, 2},
+			wantServices: []int64{10},
+			wantTuners:   []int64{0, 1},
+		},
+		{
+			name:         "remove tuner of index 0",
+			event:        mirakurun.Event{Resource: "tuner", Type: "remove", Data: []byte(`{"index":0}`)},
+			wantPrograms: []int64{1, 2},
+			wantServices: []int64{10},
+			wantTuners:   []int64{},
+		},
+		{
+			name:         "missing tuner index",
+			event:        mirakurun.Event{Resource: "tuner", Type: "update", Data: []byte(`{"name":"x"}`)},
+			wantErr:      true,
+			wantPrograms: []int64{1, 2},
+			wantServices: []int64{10},
+			wantTuners:   []int64{0},
+		},
+		{
+			name:         "unknown resource",
+			event:        mirakurun.Event{Resource: "channel", Type: "update", Data: []byte(`{"id":1}`)},
+			wantErr:      true,
+			wantPrograms: []int64{1, 2},
+			wantServices: []int64{10},
+			wantTuners:   []int64{0},
+		},
+		{
+			name:         "unknown type",
+			event:        mirakurun.Event{Resource: "program", Type: "merge", Data: []byte(`{"id":3}`)},
+			wantErr:      true,
+			wantPrograms: []int64{1, 2},
+			wantServices: []int64{10},
+			wantTuners:   []int64{0},
+		},
+		{
+			name:         "missing id",
+			event:        mirakurun.Event{Resource: "program", Type: "update", Data: []byte(`{"from":1,"to":2}`)},
+			wantErr:      true,
+			wantPrograms: []int64{1, 2},
+			wantServices: []int64{10},
+			wantTuners:   []int64{0},
+		},
+		{
+			name:         "broken data",
+			event:        mirakurun.Event{Resource: "service", Type: "update", Data: []byte(`[]`)},
+			wantErr:      true,
+			wantPrograms: []int64{1, 2},
+			wantServices: []int64{10},
+			wantTuners:   []int64{0},
+		},
+	}
+
+	for i := range tests {
+		tt := &tests[i]
+		m := newMirror(0, 0)
+		m.programs[1] = mirakurun.Program{ID: 1}
+		m.programs[2] = mirakurun.Program{ID: 2}
+		m.services[10] = mirakurun.Service{ID: 10}
+		m.tuners[0] = mirakurun.Tuner{Index: 0}
+
+		err := m.apply(&tt.event)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: apply() returned %v, want error: %v", tt.name, err, tt.wantErr)
+		}
+		if got := mirrorKeys(m.programs); !equalIDs(got, tt.wantPrograms) {
+			t.Errorf("%s: programs = %v, want %v", tt.name, got, tt.wantPrograms)
+		}
+		if got := mirrorKeys(m.services); !equalIDs(got, tt.wantServices) {
+			t.Errorf("%s: services = %v, want %v", tt.name, got, tt.wantServices)
+		}
+		if got := mirrorKeys(m.tuners); !equalIDs(got, tt.wantTuners) {
+			t.Errorf("%s: tuners = %v, want %v", tt.name, got, tt.wantTuners)
+		}
+	}
+}
+
+func TestMirrorApplyUpdatesContent(t *testing.T) {
+	m := newMirror(0, 0)
+	m.programs[1] = mirakurun.Program{ID: 1, Duration: 100}
+
+	event := &mirakurun.Event{Resource: "program", Type: "update", Data: []byte(`{"id":1,"duration":200}`)}
+	if err := m.apply(event); err != nil {
+		t.Fatalf("apply() returned %v", err)
+	}
+	if got := m.programs[1].Duration; got != 200 {
+		t.Errorf("duration = %d, want 200", got)
+	}
+}
+
+func TestDivergence(t *testing.T) {
+	mirrored := map[int64]mirakurun.Service{
+		1: {ID: 1, Name: "a"},
+		2: {ID: 2, Name: "b"},
+		3: {ID: 3, Name: "c"},
+	}
+	fetched := map[int64]mirakurun.Service{
+		1: {ID: 1, Name: "a"},
+		2: {ID: 2, Name: "changed"},
+		4: {ID: 4, Name: "d"},
+	}
+	// 2 differs, 3 is missing in fetched and 4 is missing in mirrored
+	if got := divergence(mirrored, fetched); got != 3 {
+		t.Errorf("divergence() = %d, want 3", got)
+	}
+	if got := divergence(mirrored, mirrored); got != 0 {
+		t.Errorf("divergence() of the same maps = %d, want 0", got)
+	}
+}
+
+func mirrorKeys[K int | int64, T any](items map[K]T) []int64 {
+	keys := make([]int64, 0, len(items))
+	for key := range items {
+		keys = append(keys, int64(key))
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+func equalIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
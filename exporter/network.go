@@ -0,0 +1,182 @@
+// Copyright 2021 coord_e
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//  	 http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+const unknownNetworkKind = "unknown"
+
+// NetworkKindRange maps a range of ARIB original network IDs to a kind of network.
+type NetworkKindRange struct {
+	From int
+	To   int
+	Kind string
+}
+
+// ParseNetworkKindRange parses a string in the form of "FROM[-TO]=KIND".
+// Network IDs can be written in decimal or hexadecimal with 0x prefix.
+func ParseNetworkKindRange(s string) (NetworkKindRange, error) {
+	ids, kind, ok := strings.Cut(s, "=")
+	if !ok || len(kind) == 0 {
+		return NetworkKindRange{}, fmt.Errorf("missing kind in %q", s)
+	}
+
+	fromString, toString, isRange := strings.Cut(ids, "-")
+	from, err := strconv.ParseInt(fromString, 0, 32)
+	if err != nil {
+		return NetworkKindRange{}, fmt.Errorf("invalid network ID in %q: %w", s, err)
+	}
+	to := from
+	if isRange {
+		to, err = strconv.ParseInt(toString, 0, 32)
+		if err != nil {
+			return NetworkKindRange{}, fmt.Errorf("invalid network ID in %q: %w", s, err)
+		}
+	}
+	if from > to {
+		return NetworkKindRange{}, fmt.Errorf("invalid network ID range in %q", s)
+	}
+
+	return NetworkKindRange{From: int(from), To: int(to), Kind: kind}, nil
+}
+
+// defaultNetworkKinds is a table of original network IDs assigned by ARIB TR-B14 and TR-B15.
+var defaultNetworkKinds = []NetworkKindRange{
+	{From: 0x0001, To: 0x0001, Kind: "sky_perfectv"},
+	{From: 0x0003, To: 0x0003, Kind: "sky_perfectv"},
+	{From: 0x0004, To: 0x0004, Kind: "bs_digital"},
+	{From: 0x0006, To: 0x0007, Kind: "cs110_right"},
+	{From: 0x000A, To: 0x000A, Kind: "sky_perfectv"},
+	{From: 0x000B, To: 0x000B, Kind: "bs_left"},
+	{From: 0x000C, To: 0x000C, Kind: "cs110_left"},
+}
+
+const terrestrialNetworkKind = "terrestrial"
+
+// terrestrialRegions maps region IDs of terrestrial networks defined in ARIB TR-B14 to their names.
+var terrestrialRegions = map[int]string{
+	1:  "kanto",
+	2:  "kinki",
+	3:  "chukyo",
+	4:  "hokkaido",
+	5:  "okayama_kagawa",
+	6:  "shimane_tottori",
+	10: "hokkaido_sapporo",
+	11: "hokkaido_hakodate",
+	12: "hokkaido_asahikawa",
+	13: "hokkaido_obihiro",
+	14: "hokkaido_kushiro",
+	15: "hokkaido_kitami",
+	16: "hokkaido_muroran",
+	17: "miyagi",
+	18: "akita",
+	19: "yamagata",
+	20: "iwate",
+	21: "fukushima",
+	22: "aomori",
+	23: "tokyo",
+	24: "kanagawa",
+	25: "gunma",
+	26: "ibaraki",
+	27: "chiba",
+	28: "tochigi",
+	29: "saitama",
+	30: "nagano",
+	31: "niigata",
+	32: "yamanashi",
+	33: "aichi",
+	34: "ishikawa",
+	35: "shizuoka",
+	36: "fukui",
+	37: "toyama",
+	38: "mie",
+	39: "gifu",
+	40: "osaka",
+	41: "kyoto",
+	42: "hyogo",
+	43: "wakayama",
+	44: "nara",
+	45: "shiga",
+	46: "hiroshima",
+	47: "okayama",
+	48: "shimane",
+	49: "tottori",
+	50: "yamaguchi",
+	51: "ehime",
+	52: "kagawa",
+	53: "tokushima",
+	54: "kochi",
+	55: "fukuoka",
+	56: "kumamoto",
+	57: "nagasaki",
+	58: "kagoshima",
+	59: "miyazaki",
+	60: "oita",
+	61: "saga",
+	62: "okinawa",
+}
+
+// terrestrialRegion decodes the region ID from a terrestrial network ID.
+// Terrestrial network IDs are assigned as 0x7FF0 - 0x0010 * region + broadcaster - 0x0400 * (prefectural multiplexing flag),
+// where region ranges from 1 to 63 and broadcaster from 0 to 15.
+func terrestrialRegion(networkID int) (int, bool) {
+	if networkID < 0x7800 || 0x7FEF < networkID {
+		return 0, false
+	}
+	base := 0x7FF0
+	if networkID < 0x7C00 {
+		base -= 0x0400
+	}
+	return (base - networkID + 0x000F) / 0x0010, true
+}
+
+type networkClassifier []NetworkKindRange
+
+// newNetworkClassifier creates a classifier which prefers overrides to the default table.
+func newNetworkClassifier(overrides []NetworkKindRange) networkClassifier {
+	classifier := make(networkClassifier, 0, len(overrides)+len(defaultNetworkKinds))
+	classifier = append(classifier, overrides...)
+	return append(classifier, defaultNetworkKinds...)
+}
+
+func (c networkClassifier) classify(networkID int) (string, bool) {
+	for _, r := range c {
+		if r.From <= networkID && networkID <= r.To {
+			return r.Kind, true
+		}
+	}
+	if region, ok := terrestrialRegion(networkID); ok {
+		if name, ok := terrestrialRegions[region]; ok {
+			return terrestrialNetworkKind + "_" + name, true
+		}
+		return terrestrialNetworkKind, true
+	}
+	return unknownNetworkKind, false
+}
+
+// classifyNetwork classifies the network and records it in state if it is unknown.
+func classifyNetwork(c networkClassifier, state *State, logger log.Logger, networkID int) string {
+	kind, ok := c.classify(networkID)
+	if !ok && state.unknownNetworks.observe(networkID) {
+		level.Warn(logger).Log("msg", "unknown network ID", "network_id", networkID)
+	}
+	return kind
+}
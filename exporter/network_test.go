@@ -0,0 +1,95 @@
+// Copyright 2021 coord_e
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//  	 http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"testing"
+)
+
+func TestParseNetworkKindRange(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    NetworkKindRange
+		wantErr bool
+	}{
+		{input: "4=bs", want: NetworkKindRange{From: 4, To: 4, Kind: "bs"}},
+		{input: "0x7880-0x7FE8=terrestrial", want: NetworkKindRange{From: 0x7880, To: 0x7FE8, Kind: "terrestrial"}},
+		{input: "6-7=cs", want: NetworkKindRange{From: 6, To: 7, Kind: "cs"}},
+		{input: "5-5=x", want: NetworkKindRange{From: 5, To: 5, Kind: "x"}},
+		{input: "4", wantErr: true},
+		{input: "4=", wantErr: true},
+		{input: "=bs", wantErr: true},
+		{input: "x=bs", wantErr: true},
+		{input: "4-=bs", wantErr: true},
+		{input: "7-6=cs", wantErr: true},
+		{input: "0x100000000=bs", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseNetworkKindRange(tt.input)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseNetworkKindRange(%q) = %+v, want error", tt.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseNetworkKindRange(%q) returned error: %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseNetworkKindRange(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestNetworkClassifier(t *testing.T) {
+	overrides := []NetworkKindRange{
+		{From: 0x7FE8, To: 0x7FE8, Kind: "mx"},
+		{From: 4, To: 4, Kind: "bs_override"},
+	}
+
+	tests := []struct {
+		networkID int
+		overrides []NetworkKindRange
+		want      string
+		wantOK    bool
+	}{
+		{networkID: 0x0004, want: "bs_digital", wantOK: true},
+		{networkID: 0x0007, want: "cs110_right", wantOK: true},
+		{networkID: 0x7FE0, want: "terrestrial_kanto", wantOK: true},
+		{networkID: 0x7FEF, want: "terrestrial_kanto", wantOK: true},
+		{networkID: 0x7FD0, want: "terrestrial_kinki", wantOK: true},
+		{networkID: 0x7E87, want: "terrestrial_tokyo", wantOK: true},
+		{networkID: 0x7E77, want: "terrestrial_kanagawa", wantOK: true},
+		{networkID: 0x7C10, want: "terrestrial_okinawa", wantOK: true},
+		// prefectural multiplexing flag
+		{networkID: 0x7880, want: "terrestrial_fukuoka", wantOK: true},
+		// region IDs not assigned
+		{networkID: 0x7F80, want: "terrestrial", wantOK: true},
+		{networkID: 0x7C00, want: "terrestrial", wantOK: true},
+		{networkID: 0x7FF0, want: unknownNetworkKind, wantOK: false},
+		{networkID: 0x77FF, want: unknownNetworkKind, wantOK: false},
+		{networkID: 99, want: unknownNetworkKind, wantOK: false},
+		{networkID: 0x7FE8, overrides: overrides, want: "mx", wantOK: true},
+		{networkID: 0x0004, overrides: overrides, want: "bs_override", wantOK: true},
+	}
+
+	for _, tt := range tests {
+		got, ok := newNetworkClassifier(tt.overrides).classify(tt.networkID)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("classify(%#x) = (%q, %v), want (%q, %v)", tt.networkID, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -15,11 +15,14 @@ package exporter
 
 import (
 	"context"
+	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
 
 	"github.com/coord-e/mirakurun_exporter/mirakurun"
 )
@@ -27,46 +30,546 @@ import (
 type programsExporter struct {
 	ctx    context.Context
 	client *mirakurun.Client
+	source source
+	state  *State
 	logger log.Logger
 
-	programs *prometheus.Desc
+	serviceNameLabel bool
+	networks         networkClassifier
+	windows          []ProgramsWindow
+	scheduleWindow   time.Duration
+	currentInfo      bool
+	genreAggregate   bool
+	seriesExpiry     time.Duration
+	serviceIDs       []int
+	filter           ServiceFilter
+
+	programs         *prometheus.Desc
+	horizon          *prometheus.Desc
+	upcomingPrograms *prometheus.Desc
+	scheduleGaps     *prometheus.Desc
+	gapDuration      *prometheus.Desc
+	largestGap       *prometheus.Desc
+	overlapDuration  *prometheus.Desc
+
+	onAir              *prometheus.Desc
+	currentEnd         *prometheus.Desc
+	currentProgramInfo *prometheus.Desc
+
+	programsByGenre *prometheus.Desc
+	programsByVideo *prometheus.Desc
+	programsByAudio *prometheus.Desc
+
+	endedPrograms               *prometheus.Desc
+	allEndedPrograms            *prometheus.Desc
+	unnamedPrograms             *prometheus.Desc
+	nonPositiveDurationPrograms *prometheus.Desc
+
+	activeSeries   *prometheus.Desc
+	expiringSeries *prometheus.Desc
+	repeatPrograms *prometheus.Desc
+
+	relatedItemPrograms *prometheus.Desc
+	activeRelays        *prometheus.Desc
+}
+
+type videoKey struct {
+	programServiceKey
+	resolution string
+	codec      string
+}
+
+type relatedItemKey struct {
+	programServiceKey
+	itemType string
+}
+
+type relayKey struct {
+	programServiceKey
+	targetServiceID int
+	targetNetworkID int
+}
+
+type audioKey struct {
+	programServiceKey
+	samplingRate string
+	langs        string
+}
+
+// programServiceKey identifies a service, as service IDs are unique only within a network.
+type programServiceKey struct {
+	networkID int
+	serviceID int
+}
+
+// ProgramsWindow is a window from now to count upcoming programs in.
+type ProgramsWindow struct {
+	Duration time.Duration
+	// Label is the window as written by the user, used as the value of window label
+	Label string
+}
+
+// ParseProgramsWindow parses a duration such as "1h" or "7d".
+func ParseProgramsWindow(s string) (ProgramsWindow, error) {
+	d, err := model.ParseDuration(s)
+	if err != nil {
+		return ProgramsWindow{}, fmt.Errorf("invalid window %q: %w", s, err)
+	}
+	return ProgramsWindow{Duration: time.Duration(d), Label: s}, nil
 }
 
 // Verify if programsExporter implements prometheus.Collector
 var _ prometheus.Collector = (*programsExporter)(nil)
 
-func newProgramsExporter(ctx context.Context, client *mirakurun.Client, logger log.Logger) *programsExporter {
+func newProgramsExporter(ctx context.Context, client *mirakurun.Client, config Config, state *State, logger log.Logger) *programsExporter {
 	const subsystem = "programs"
+	const programSubsystem = "program"
+
+	serviceLabels := []string{"service_id", "network_id", "network_kind"}
+	if config.ServiceNameLabel {
+		serviceLabels = append(serviceLabels, "service_name")
+	}
+
+	genreLabels := withLabels(serviceLabels, "genre")
+	if config.ProgramsGenreAggregate {
+		genreLabels = []string{"genre"}
+	}
 
 	return &programsExporter{
 		ctx:    ctx,
 		client: client,
+		source: state.source(client),
+		state:  state,
 		logger: logger,
 
+		serviceNameLabel: config.ServiceNameLabel,
+		networks:         newNetworkClassifier(config.NetworkKinds),
+		windows:          config.ProgramsWindows,
+		scheduleWindow:   config.ProgramsScheduleWindow,
+		currentInfo:      config.ProgramsCurrentInfo,
+		genreAggregate:   config.ProgramsGenreAggregate,
+		seriesExpiry:     config.ProgramsSeriesExpiryThreshold,
+		serviceIDs:       config.ProgramsServiceIDs,
+		filter:           config.ServiceFilter,
+
 		programs: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, subsystem, "stored_programs"),
 			"Number of programs stored in Mirakurun.",
-			[]string{"service_id"}, nil),
+			serviceLabels, nil),
+		horizon: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "horizon_seconds"),
+			"Duration from now to the end of the last program of the service in seconds.",
+			serviceLabels, nil),
+		upcomingPrograms: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "upcoming_programs"),
+			"Number of programs starting within the window from now.",
+			withLabels(serviceLabels, "window"), nil),
+		scheduleGaps: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "schedule_gaps"),
+			"Number of gaps in programs of the service within the schedule window, including those before the first and after the last program.",
+			serviceLabels, nil),
+		gapDuration: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "schedule_gap_seconds"),
+			"Total duration of gaps between programs of the service within the schedule window in seconds.",
+			serviceLabels, nil),
+		largestGap: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "schedule_largest_gap_seconds"),
+			"Duration of the largest gap between programs of the service within the schedule window in seconds.",
+			serviceLabels, nil),
+		overlapDuration: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "schedule_overlap_seconds"),
+			"Total duration of overlaps between programs of the service within the schedule window in seconds.",
+			serviceLabels, nil),
+		onAir: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, programSubsystem, "on_air"),
+			"Whether a program currently on air is known for the service (1) or not (0).",
+			serviceLabels, nil),
+		currentEnd: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, programSubsystem, "current_end_timestamp_seconds"),
+			"Unix time when the program currently on air ends.",
+			serviceLabels, nil),
+		currentProgramInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, programSubsystem, "current_info"),
+			"A metric with a constant '1' value labeled by metadata of the program currently on air.",
+			withLabels(serviceLabels, "event_id", "name"), nil),
+		programsByGenre: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "by_genre"),
+			"Number of programs stored in Mirakurun labeled by ARIB genre.",
+			genreLabels, nil),
+		programsByVideo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "by_video"),
+			"Number of upcoming programs labeled by video resolution and codec.",
+			withLabels(serviceLabels, "resolution", "codec"), nil),
+		programsByAudio: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "by_audio"),
+			"Number of upcoming programs labeled by audio sampling rate and number of languages.",
+			withLabels(serviceLabels, "sampling_rate", "langs"), nil),
+		endedPrograms: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "ended_programs"),
+			"Number of programs already ended but still stored in Mirakurun.",
+			serviceLabels, nil),
+		allEndedPrograms: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "all_ended_programs"),
+			"Number of programs already ended but still stored in Mirakurun over all services.",
+			nil, nil),
+		unnamedPrograms: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "unnamed_programs"),
+			"Number of programs without name stored in Mirakurun.",
+			serviceLabels, nil),
+		nonPositiveDurationPrograms: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "non_positive_duration_programs"),
+			"Number of programs with zero or negative duration stored in Mirakurun.",
+			serviceLabels, nil),
+		activeSeries: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "active_series"),
+			"Number of distinct series not yet expired in programs of the service.",
+			serviceLabels, nil),
+		expiringSeries: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "expiring_series"),
+			"Number of distinct series expiring within the threshold in programs of the service.",
+			withLabels(serviceLabels, "threshold"), nil),
+		repeatPrograms: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "repeat_programs"),
+			"Number of programs flagged as repeats of a series.",
+			serviceLabels, nil),
+		relatedItemPrograms: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "related_item_programs"),
+			"Number of programs with related items labeled by type of the related item.",
+			withLabels(serviceLabels, "type"), nil),
+		activeRelays: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, programSubsystem, "active_relays"),
+			"Number of event relays from the program currently on air labeled by the target service.",
+			withLabels(serviceLabels, "target_service_id", "target_network_id"), nil),
 	}
 }
 
+// withLabels returns a new slice of labels with extra labels appended, leaving labels untouched.
+func withLabels(labels []string, extra ...string) []string {
+	result := make([]string, 0, len(labels)+len(extra))
+	result = append(result, labels...)
+	return append(result, extra...)
+}
+
 func (e *programsExporter) Describe(ch chan<- *prometheus.Desc) {
 	ch <- e.programs
+	ch <- e.horizon
+	ch <- e.upcomingPrograms
+	ch <- e.scheduleGaps
+	ch <- e.gapDuration
+	ch <- e.largestGap
+	ch <- e.overlapDuration
+	ch <- e.onAir
+	ch <- e.currentEnd
+	if e.currentInfo {
+		ch <- e.currentProgramInfo
+	}
+	ch <- e.programsByGenre
+	ch <- e.programsByVideo
+	ch <- e.programsByAudio
+	ch <- e.endedPrograms
+	ch <- e.allEndedPrograms
+	ch <- e.unnamedPrograms
+	ch <- e.nonPositiveDurationPrograms
+	ch <- e.activeSeries
+	ch <- e.expiringSeries
+	ch <- e.repeatPrograms
+	ch <- e.relatedItemPrograms
+	ch <- e.activeRelays
 }
 
 func (e *programsExporter) Collect(ch chan<- prometheus.Metric) {
-	programs, err := e.client.GetPrograms(e.ctx)
+	programs, err := e.fetchPrograms()
 	if err != nil {
 		level.Error(e.logger).Log("msg", "failed to fetch Mirakurun programs", "err", err)
 		return
 	}
 
-	counts := map[int]int{}
-	for _, program := range *programs {
-		counts[program.ServiceID]++
+	serviceNames := map[[2]int]string{}
+	serviceAttrs := map[[2]int]serviceAttributes{}
+	if e.serviceNameLabel || !e.filter.empty() {
+		services, err := e.source.GetServices(e.ctx)
+		if err != nil {
+			level.Error(e.logger).Log("msg", "failed to fetch Mirakurun services", "err", err)
+			return
+		}
+		for i := range *services {
+			service := &(*services)[i]
+			id := [2]int{service.NetworkID, service.ServiceID}
+			serviceNames[id] = e.state.labels.limit(serviceNameLimitKey, service.Name)
+			serviceAttrs[id] = serviceAttributesOf(service)
+		}
+	}
+	serviceLabels := func(key programServiceKey) []string {
+		networkKind := classifyNetwork(e.networks, e.state, e.logger, key.networkID)
+		labels := []string{strconv.Itoa(key.serviceID), strconv.Itoa(key.networkID), networkKind}
+		if e.serviceNameLabel {
+			labels = append(labels, serviceNames[[2]int{key.networkID, key.serviceID}])
+		}
+		return labels
+	}
+
+	now := time.Now().UnixMilli()
+	counts := map[programServiceKey]int{}
+	lastEnds := map[programServiceKey]int64{}
+	upcomings := map[programServiceKey][]int{}
+	schedules := map[programServiceKey][]*mirakurun.Program{}
+	genres := map[programServiceKey]map[string]int{}
+	videos := map[videoKey]int{}
+	audios := map[audioKey]int{}
+	ended := map[programServiceKey]int{}
+	unnamed := map[programServiceKey]int{}
+	nonPositiveDuration := map[programServiceKey]int{}
+	var allEnded int
+	activeSeries := map[programServiceKey]map[int]struct{}{}
+	expiringSeries := map[programServiceKey]map[int]struct{}{}
+	repeats := map[programServiceKey]int{}
+	relatedItems := map[relatedItemKey]int{}
+	for i := range *programs {
+		program := &(*programs)[i]
+		if !e.filter.empty() {
+			attrs, ok := serviceAttrs[[2]int{program.NetworkID, program.ServiceID}]
+			if !ok {
+				attrs = serviceAttributes{serviceID: program.ServiceID, networkID: program.NetworkID}
+			}
+			if !e.filter.allows(attrs) {
+				continue
+			}
+		}
+		key := programServiceKey{networkID: program.NetworkID, serviceID: program.ServiceID}
+		counts[key]++
+		schedules[key] = append(schedules[key], program)
+
+		if program.Duration != undeterminedDuration && program.StartAt+program.Duration < now {
+			ended[key]++
+			allEnded++
+		}
+		if program.Name == nil || len(*program.Name) == 0 {
+			unnamed[key]++
+		}
+		if program.Duration <= 0 {
+			nonPositiveDuration[key]++
+		}
+
+		if series := program.Series; series != nil {
+			// expiresAt is in milliseconds
+			if series.ExpiresAt > now {
+				if _, ok := activeSeries[key]; !ok {
+					activeSeries[key] = map[int]struct{}{}
+				}
+				activeSeries[key][series.ID] = struct{}{}
+			}
+			if series.ExpiresAt > now && series.ExpiresAt <= now+e.seriesExpiry.Milliseconds() {
+				if _, ok := expiringSeries[key]; !ok {
+					expiringSeries[key] = map[int]struct{}{}
+				}
+				expiringSeries[key][series.ID] = struct{}{}
+			}
+			if series.Repeat > 0 {
+				repeats[key]++
+			}
+		}
+
+		if program.RelatedItems != nil {
+			seen := map[string]bool{}
+			for _, item := range *program.RelatedItems {
+				itemType := "unknown"
+				if item.Type != nil {
+					itemType = *item.Type
+				}
+				if !seen[itemType] {
+					seen[itemType] = true
+					relatedItems[relatedItemKey{programServiceKey: key, itemType: itemType}]++
+				}
+			}
+		}
+
+		genreKey := key
+		if e.genreAggregate {
+			genreKey = programServiceKey{}
+		}
+		if _, ok := genres[genreKey]; !ok {
+			genres[genreKey] = map[string]int{}
+		}
+		for _, genre := range programGenres(program) {
+			genres[genreKey][genre]++
+		}
+
+		if program.StartAt+program.Duration > now || program.Duration == undeterminedDuration {
+			video := videoKey{programServiceKey: key, resolution: "none", codec: "none"}
+			if program.Video != nil {
+				video.resolution = program.Video.Resolution
+				video.codec = program.Video.Type
+			}
+			videos[video]++
+
+			audio := audioKey{programServiceKey: key, samplingRate: "none", langs: "0"}
+			if program.Audio != nil {
+				audio.samplingRate = strconv.Itoa(program.Audio.SamplingRate)
+				if program.Audio.Langs != nil {
+					audio.langs = strconv.Itoa(len(*program.Audio.Langs))
+				}
+			}
+			audios[audio]++
+		}
+
+		// startAt and duration are in milliseconds
+		if end := program.StartAt + program.Duration; end > lastEnds[key] {
+			lastEnds[key] = end
+		}
+
+		if _, ok := upcomings[key]; !ok {
+			upcomings[key] = make([]int, len(e.windows))
+		}
+		for i, window := range e.windows {
+			if now <= program.StartAt && program.StartAt < now+window.Duration.Milliseconds() {
+				upcomings[key][i]++
+			}
+		}
+	}
+
+	for key, count := range counts {
+		ch <- prometheus.MustNewConstMetric(e.programs, prometheus.GaugeValue, float64(count), serviceLabels(key)...)
+	}
+	for key, end := range lastEnds {
+		ch <- prometheus.MustNewConstMetric(e.horizon, prometheus.GaugeValue, float64(end-now)/1000, serviceLabels(key)...)
+	}
+	for key, upcoming := range upcomings {
+		for i, window := range e.windows {
+			labels := append(serviceLabels(key), window.Label)
+			ch <- prometheus.MustNewConstMetric(e.upcomingPrograms, prometheus.GaugeValue, float64(upcoming[i]), labels...)
+		}
+	}
+	// schedules are analyzed up to the farthest program stored for any service,
+	// so that the window exceeding what Mirakurun has gathered is not reported as gaps
+	scheduleEnd := now + e.scheduleWindow.Milliseconds()
+	var horizon int64
+	for _, end := range lastEnds {
+		if end > horizon {
+			horizon = end
+		}
+	}
+	if horizon < scheduleEnd {
+		scheduleEnd = horizon
+	}
+	for key, schedule := range schedules {
+		stats := analyzeSchedule(schedule, now, scheduleEnd)
+		ch <- prometheus.MustNewConstMetric(e.scheduleGaps, prometheus.GaugeValue, float64(stats.gaps), serviceLabels(key)...)
+		ch <- prometheus.MustNewConstMetric(e.gapDuration, prometheus.GaugeValue, float64(stats.gapTotal)/1000, serviceLabels(key)...)
+		ch <- prometheus.MustNewConstMetric(e.largestGap, prometheus.GaugeValue, float64(stats.gapLargest)/1000, serviceLabels(key)...)
+		ch <- prometheus.MustNewConstMetric(e.overlapDuration, prometheus.GaugeValue, float64(stats.overlap)/1000, serviceLabels(key)...)
+
+		current := currentProgram(schedule, now)
+		if current == nil {
+			ch <- prometheus.MustNewConstMetric(e.onAir, prometheus.GaugeValue, 0, serviceLabels(key)...)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(e.onAir, prometheus.GaugeValue, 1, serviceLabels(key)...)
+		if current.Duration != undeterminedDuration {
+			ch <- prometheus.MustNewConstMetric(e.currentEnd, prometheus.GaugeValue, float64(current.StartAt+current.Duration)/1000, serviceLabels(key)...)
+		}
+		if e.currentInfo {
+			var name string
+			if current.Name != nil {
+				name = e.state.labels.limit(prometheus.BuildFQName(namespace, "program", "current_info"), *current.Name)
+			}
+			labels := append(serviceLabels(key), strconv.Itoa(current.EventID), name)
+			ch <- prometheus.MustNewConstMetric(e.currentProgramInfo, prometheus.UntypedValue, 1.0, labels...)
+		}
+		for relay, count := range programRelays(current, key) {
+			labels := append(serviceLabels(key), strconv.Itoa(relay.targetServiceID), strconv.Itoa(relay.targetNetworkID))
+			ch <- prometheus.MustNewConstMetric(e.activeRelays, prometheus.GaugeValue, float64(count), labels...)
+		}
+	}
+	for key, count := range relatedItems {
+		labels := append(serviceLabels(key.programServiceKey), key.itemType)
+		ch <- prometheus.MustNewConstMetric(e.relatedItemPrograms, prometheus.GaugeValue, float64(count), labels...)
+	}
+	for key, counts := range genres {
+		for genre, count := range counts {
+			labels := []string{genre}
+			if !e.genreAggregate {
+				labels = append(serviceLabels(key), genre)
+			}
+			ch <- prometheus.MustNewConstMetric(e.programsByGenre, prometheus.GaugeValue, float64(count), labels...)
+		}
+	}
+	for key := range counts {
+		ch <- prometheus.MustNewConstMetric(e.endedPrograms, prometheus.GaugeValue, float64(ended[key]), serviceLabels(key)...)
+		ch <- prometheus.MustNewConstMetric(e.unnamedPrograms, prometheus.GaugeValue, float64(unnamed[key]), serviceLabels(key)...)
+		ch <- prometheus.MustNewConstMetric(e.nonPositiveDurationPrograms, prometheus.GaugeValue, float64(nonPositiveDuration[key]), serviceLabels(key)...)
+	}
+	ch <- prometheus.MustNewConstMetric(e.allEndedPrograms, prometheus.GaugeValue, float64(allEnded))
+	for key := range counts {
+		ch <- prometheus.MustNewConstMetric(e.activeSeries, prometheus.GaugeValue, float64(len(activeSeries[key])), serviceLabels(key)...)
+		labels := append(serviceLabels(key), model.Duration(e.seriesExpiry).String())
+		ch <- prometheus.MustNewConstMetric(e.expiringSeries, prometheus.GaugeValue, float64(len(expiringSeries[key])), labels...)
+		ch <- prometheus.MustNewConstMetric(e.repeatPrograms, prometheus.GaugeValue, float64(repeats[key]), serviceLabels(key)...)
+	}
+	for key, count := range videos {
+		labels := append(serviceLabels(key.programServiceKey), key.resolution, key.codec)
+		ch <- prometheus.MustNewConstMetric(e.programsByVideo, prometheus.GaugeValue, float64(count), labels...)
+	}
+	for key, count := range audios {
+		labels := append(serviceLabels(key.programServiceKey), key.samplingRate, key.langs)
+		ch <- prometheus.MustNewConstMetric(e.programsByAudio, prometheus.GaugeValue, float64(count), labels...)
+	}
+}
+
+// fetchPrograms fetches programs of the allowed services, or all programs if no service is specified.
+func (e *programsExporter) fetchPrograms() (*mirakurun.ProgramsResponse, error) {
+	if len(e.serviceIDs) == 0 {
+		return e.source.GetPrograms(e.ctx, nil)
+	}
+
+	var programs mirakurun.ProgramsResponse
+	for _, serviceID := range e.serviceIDs {
+		serviceID := serviceID
+		servicePrograms, err := e.source.GetPrograms(e.ctx, &mirakurun.ProgramsQuery{ServiceID: &serviceID})
+		if err != nil {
+			return nil, err
+		}
+		programs = append(programs, *servicePrograms...)
+	}
+	return &programs, nil
+}
+
+// programRelays counts relay items of the program pointing to other services.
+func programRelays(program *mirakurun.Program, key programServiceKey) map[relayKey]int {
+	relays := map[relayKey]int{}
+	if program.RelatedItems == nil {
+		return relays
+	}
+
+	for _, item := range *program.RelatedItems {
+		if item.Type == nil || *item.Type != "relay" {
+			continue
+		}
+		networkID := program.NetworkID
+		if item.NetworkID != nil {
+			networkID = *item.NetworkID
+		}
+		if networkID == program.NetworkID && item.ServiceID == program.ServiceID {
+			continue
+		}
+		relays[relayKey{programServiceKey: key, targetServiceID: item.ServiceID, targetNetworkID: networkID}]++
+	}
+	return relays
+}
+
+// programGenres returns distinct genre names of the program, or "none" if it has no genre.
+func programGenres(program *mirakurun.Program) []string {
+	if program.Genres == nil || len(*program.Genres) == 0 {
+		return []string{"none"}
 	}
 
-	for serviceID, count := range counts {
-		ch <- prometheus.MustNewConstMetric(e.programs, prometheus.GaugeValue, float64(count), strconv.Itoa(serviceID))
+	var names []string
+	seen := map[string]bool{}
+	for _, genre := range *program.Genres {
+		name := genreName(genre.Lv1)
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
 	}
+	return names
 }
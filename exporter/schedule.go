@@ -0,0 +1,129 @@
+// Copyright 2021 coord_e
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//  	 http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"sort"
+
+	"github.com/coord-e/mirakurun_exporter/mirakurun"
+)
+
+// Mirakurun sets duration to 1 when the end of the program is undetermined.
+const undeterminedDuration = 1
+
+type scheduleStats struct {
+	gaps       int
+	gapTotal   int64
+	gapLargest int64
+	overlap    int64
+}
+
+// analyzeSchedule finds gaps and overlaps between programs of a service in [from, to).
+// All times are in milliseconds. A program with undetermined duration is assumed to last until the next program starts.
+// Holes from from until the first program and from the last program until to are counted as gaps as well.
+// programs are sorted in place.
+func analyzeSchedule(programs []*mirakurun.Program, from, to int64) scheduleStats {
+	var stats scheduleStats
+	if to <= from {
+		return stats
+	}
+
+	sort.Slice(programs, func(i, j int) bool {
+		return programs[i].StartAt < programs[j].StartAt
+	})
+
+	coveredUntil, openEnded := from, false
+	for _, program := range programs {
+		if program.StartAt >= to {
+			break
+		}
+		end := program.StartAt + program.Duration
+		undetermined := program.Duration == undeterminedDuration
+		if !undetermined && end <= from {
+			continue
+		}
+
+		start := program.StartAt
+		if start < from {
+			start = from
+		}
+		if end > to {
+			end = to
+		}
+
+		switch {
+		case openEnded:
+			if start > coveredUntil {
+				coveredUntil = start
+			}
+		case start > coveredUntil:
+			stats.addGap(start - coveredUntil)
+		case start < coveredUntil && !undetermined:
+			overlapEnd := coveredUntil
+			if end < overlapEnd {
+				overlapEnd = end
+			}
+			stats.overlap += overlapEnd - start
+		}
+
+		openEnded = undetermined
+		if !undetermined && end > coveredUntil {
+			coveredUntil = end
+		}
+	}
+	if !openEnded && coveredUntil < to {
+		stats.addGap(to - coveredUntil)
+	}
+
+	return stats
+}
+
+func (s *scheduleStats) addGap(gap int64) {
+	s.gaps++
+	s.gapTotal += gap
+	if gap > s.gapLargest {
+		s.gapLargest = gap
+	}
+}
+
+// currentProgram returns the program on air at now, or nil if there is none.
+// If programs overlap, the one started last among those still running is chosen.
+// A program with undetermined duration is considered on air until another program starts.
+func currentProgram(programs []*mirakurun.Program, now int64) *mirakurun.Program {
+	var latestStart int64
+	started := false
+	for _, program := range programs {
+		if program.StartAt <= now && (!started || program.StartAt > latestStart) {
+			latestStart = program.StartAt
+			started = true
+		}
+	}
+
+	var current *mirakurun.Program
+	for _, program := range programs {
+		if program.StartAt > now {
+			continue
+		}
+		var running bool
+		if program.Duration == undeterminedDuration {
+			running = program.StartAt == latestStart
+		} else {
+			running = now < program.StartAt+program.Duration
+		}
+		if running && (current == nil || program.StartAt > current.StartAt) {
+			current = program
+		}
+	}
+	return current
+}
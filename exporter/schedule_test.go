@@ -0,0 +1,230 @@
+// Copyright 2021 coord_e
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//  	 http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"testing"
+
+	"github.com/coord-e/mirakurun_exporter/mirakurun"
+)
+
+// schedule builds programs from pairs of start and duration.
+func schedule(spans ...[2]int64) []*mirakurun.Program {
+	programs := make([]*mirakurun.Program, 0, len(spans))
+	for i, span := range spans {
+		programs = append(programs, &mirakurun.Program{ID: int64(i), StartAt: span[0], Duration: span[1]})
+	}
+	return programs
+}
+
+func TestAnalyzeSchedule(t *testing.T) {
+	tests := []struct {
+		name     string
+		programs []*mirakurun.Program
+		from, to int64
+		want     scheduleStats
+	}{
+		{
+			name:     "contiguous",
+			programs: schedule([2]int64{0, 100}, [2]int64{100, 100}),
+			from:     0, to: 200,
+			want: scheduleStats{},
+		},
+		{
+			name:     "unsorted",
+			programs: schedule([2]int64{100, 100}, [2]int64{0, 100}),
+			from:     0, to: 200,
+			want: scheduleStats{},
+		},
+		{
+			name:     "no programs",
+			programs: nil,
+			from:     0, to: 200,
+			want: scheduleStats{gaps: 1, gapTotal: 200, gapLargest: 200},
+		},
+		{
+			name:     "programs outside the window",
+			programs: schedule([2]int64{-200, 100}, [2]int64{300, 100}),
+			from:     0, to: 200,
+			want: scheduleStats{gaps: 1, gapTotal: 200, gapLargest: 200},
+		},
+		{
+			name:     "leading gap",
+			programs: schedule([2]int64{50, 150}),
+			from:     0, to: 200,
+			want: scheduleStats{gaps: 1, gapTotal: 50, gapLargest: 50},
+		},
+		{
+			name:     "trailing gap",
+			programs: schedule([2]int64{0, 150}),
+			from:     0, to: 200,
+			want: scheduleStats{gaps: 1, gapTotal: 50, gapLargest: 50},
+		},
+		{
+			name:     "gaps between programs",
+			programs: schedule([2]int64{0, 50}, [2]int64{60, 40}, [2]int64{130, 70}),
+			from:     0, to: 200,
+			want: scheduleStats{gaps: 2, gapTotal: 40, gapLargest: 30},
+		},
+		{
+			name:     "program started before the window",
+			programs: schedule([2]int64{-50, 100}, [2]int64{50, 150}),
+			from:     0, to: 200,
+			want: scheduleStats{},
+		},
+		{
+			name:     "overlap",
+			programs: schedule([2]int64{0, 120}, [2]int64{100, 100}),
+			from:     0, to: 200,
+			want: scheduleStats{overlap: 20},
+		},
+		{
+			name:     "overlap contained",
+			programs: schedule([2]int64{0, 200}, [2]int64{50, 50}),
+			from:     0, to: 200,
+			want: scheduleStats{overlap: 50},
+		},
+		{
+			name:     "overlap clipped by the window",
+			programs: schedule([2]int64{0, 300}, [2]int64{150, 150}),
+			from:     0, to: 200,
+			want: scheduleStats{overlap: 50},
+		},
+		{
+			name:     "undetermined program lasts until the next program",
+			programs: schedule([2]int64{0, undeterminedDuration}, [2]int64{100, 100}),
+			from:     0, to: 200,
+			want: scheduleStats{},
+		},
+		{
+			name:     "undetermined program lasts until the end of the window",
+			programs: schedule([2]int64{0, 100}, [2]int64{100, undeterminedDuration}),
+			from:     0, to: 200,
+			want: scheduleStats{},
+		},
+		{
+			name:     "undetermined program started before the window",
+			programs: schedule([2]int64{-100, undeterminedDuration}, [2]int64{100, 100}),
+			from:     0, to: 200,
+			want: scheduleStats{},
+		},
+		{
+			name:     "undetermined program after a gap",
+			programs: schedule([2]int64{0, 50}, [2]int64{100, undeterminedDuration}),
+			from:     0, to: 200,
+			want: scheduleStats{gaps: 1, gapTotal: 50, gapLargest: 50},
+		},
+		{
+			name:     "undetermined program does not overlap",
+			programs: schedule([2]int64{0, 200}, [2]int64{100, undeterminedDuration}),
+			from:     0, to: 200,
+			want: scheduleStats{},
+		},
+		{
+			name:     "empty window",
+			programs: schedule([2]int64{0, 100}),
+			from:     200, to: 100,
+			want: scheduleStats{},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := analyzeSchedule(tt.programs, tt.from, tt.to); got != tt.want {
+			t.Errorf("%s: analyzeSchedule() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCurrentProgram(t *testing.T) {
+	tests := []struct {
+		name     string
+		programs []*mirakurun.Program
+		now      int64
+		wantID   int64
+		wantNone bool
+	}{
+		{
+			name:     "no programs",
+			programs: nil,
+			now:      0,
+			wantNone: true,
+		},
+		{
+			name:     "on air",
+			programs: schedule([2]int64{0, 100}, [2]int64{100, 100}),
+			now:      150,
+			wantID:   1,
+		},
+		{
+			name:     "starting now",
+			programs: schedule([2]int64{0, 100}, [2]int64{100, 100}),
+			now:      100,
+			wantID:   1,
+		},
+		{
+			name:     "in a gap",
+			programs: schedule([2]int64{0, 50}, [2]int64{100, 100}),
+			now:      70,
+			wantNone: true,
+		},
+		{
+			name:     "before the first program",
+			programs: schedule([2]int64{100, 100}),
+			now:      50,
+			wantNone: true,
+		},
+		{
+			name:     "overlapping programs prefer the later start",
+			programs: schedule([2]int64{0, 200}, [2]int64{50, 100}),
+			now:      100,
+			wantID:   1,
+		},
+		{
+			name:     "later program already ended",
+			programs: schedule([2]int64{0, 200}, [2]int64{50, 20}),
+			now:      100,
+			wantID:   0,
+		},
+		{
+			name:     "undetermined program",
+			programs: schedule([2]int64{0, 100}, [2]int64{100, undeterminedDuration}),
+			now:      500,
+			wantID:   1,
+		},
+		{
+			name:     "undetermined program superseded",
+			programs: schedule([2]int64{0, undeterminedDuration}, [2]int64{100, 50}),
+			now:      200,
+			wantNone: true,
+		},
+		{
+			name:     "determined program preferred to an earlier undetermined one",
+			programs: schedule([2]int64{0, undeterminedDuration}, [2]int64{100, 100}),
+			now:      150,
+			wantID:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		got := currentProgram(tt.programs, tt.now)
+		switch {
+		case tt.wantNone && got != nil:
+			t.Errorf("%s: currentProgram() = %d, want nil", tt.name, got.ID)
+		case !tt.wantNone && got == nil:
+			t.Errorf("%s: currentProgram() = nil, want %d", tt.name, tt.wantID)
+		case !tt.wantNone && got.ID != tt.wantID:
+			t.Errorf("%s: currentProgram() = %d, want %d", tt.name, got.ID, tt.wantID)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2021 coord_e
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//  	 http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"strconv"
+	"strings"
+)
+
+type semver struct {
+	major      int
+	minor      int
+	patch      int
+	prerelease string
+}
+
+// parseSemver parses version strings such as "3.9.0-rc.4" reported by Mirakurun.
+// A leading "v" and build metadata are ignored.
+func parseSemver(s string) (semver, bool) {
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		s = s[:i]
+	}
+
+	var v semver
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		v.prerelease = s[i+1:]
+		s = s[:i]
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return semver{}, false
+	}
+	nums := make([]int, len(parts))
+	for i, part := range parts {
+		if !isNumericIdentifier(part) {
+			return semver{}, false
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return semver{}, false
+		}
+		nums[i] = n
+	}
+	v.major, v.minor, v.patch = nums[0], nums[1], nums[2]
+
+	return v, true
+}
+
+// compare returns -1, 0 or 1 if v is lower than, equal to or greater than w in the semver precedence.
+func (v semver) compare(w semver) int {
+	for _, d := range []int{v.major - w.major, v.minor - w.minor, v.patch - w.patch} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+
+	// a version without prerelease has higher precedence than one with prerelease
+	switch {
+	case v.prerelease == w.prerelease:
+		return 0
+	case len(v.prerelease) == 0:
+		return 1
+	case len(w.prerelease) == 0:
+		return -1
+	}
+
+	vs := strings.Split(v.prerelease, ".")
+	ws := strings.Split(w.prerelease, ".")
+	for i := 0; i < len(vs) && i < len(ws); i++ {
+		if c := comparePrereleaseIdentifier(vs[i], ws[i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(vs) < len(ws):
+		return -1
+	case len(vs) > len(ws):
+		return 1
+	default:
+		return 0
+	}
+}
+
+// comparePrereleaseIdentifier compares numeric identifiers numerically and others lexically.
+// Numeric identifiers have lower precedence than alphanumeric ones.
+func comparePrereleaseIdentifier(a, b string) int {
+	aNumeric, bNumeric := isNumericIdentifier(a), isNumericIdentifier(b)
+	switch {
+	case aNumeric && bNumeric:
+		// compare by length first so that numbers of any size are compared correctly
+		a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+		switch {
+		case len(a) < len(b):
+			return -1
+		case len(a) > len(b):
+			return 1
+		default:
+			return strings.Compare(a, b)
+		}
+	case aNumeric:
+		return -1
+	case bNumeric:
+		return 1
+	default:
+		return strings.Compare(a, b)
+	}
+}
+
+// isNumericIdentifier reports whether s consists only of ASCII digits.
+func isNumericIdentifier(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || '9' < s[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,94 @@
+// Copyright 2021 coord_e
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//  	 http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"testing"
+)
+
+func TestParseSemver(t *testing.T) {
+	tests := []struct {
+		input  string
+		want   semver
+		wantOK bool
+	}{
+		{input: "3.9.0", want: semver{major: 3, minor: 9, patch: 0}, wantOK: true},
+		{input: "v3.9.0", want: semver{major: 3, minor: 9, patch: 0}, wantOK: true},
+		{input: "3.9.0-rc.4", want: semver{major: 3, minor: 9, patch: 0, prerelease: "rc.4"}, wantOK: true},
+		{input: "3.9.0+build.1", want: semver{major: 3, minor: 9, patch: 0}, wantOK: true},
+		{input: "3.9.0-beta-1+build", want: semver{major: 3, minor: 9, patch: 0, prerelease: "beta-1"}, wantOK: true},
+		{input: "3.9", wantOK: false},
+		{input: "3.9.0.1", wantOK: false},
+		{input: "3.x.0", wantOK: false},
+		{input: "3.9.+1", wantOK: false},
+		{input: "3..0", wantOK: false},
+		{input: "", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseSemver(tt.input)
+		if ok != tt.wantOK || (ok && got != tt.want) {
+			t.Errorf("parseSemver(%q) = (%+v, %v), want (%+v, %v)", tt.input, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestSemverCompare(t *testing.T) {
+	// in ascending order of precedence, as in the example of the semver specification
+	ordered := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.0.1",
+		"1.1.0",
+		"2.0.0-rc.99999999999999999999",
+		"2.0.0-rc.100000000000000000000",
+		"2.0.0",
+		"10.0.0",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			v, _ := parseSemver(ordered[i])
+			w, _ := parseSemver(ordered[j])
+			want := 0
+			switch {
+			case i < j:
+				want = -1
+			case i > j:
+				want = 1
+			}
+			if got := v.compare(w); got != want {
+				t.Errorf("compare(%q, %q) = %d, want %d", ordered[i], ordered[j], got, want)
+			}
+		}
+	}
+
+	equal := [][2]string{
+		{"1.0.0", "v1.0.0"},
+		{"1.0.0+a", "1.0.0+b"},
+		{"1.0.0-rc.01", "1.0.0-rc.1"},
+	}
+	for _, pair := range equal {
+		v, _ := parseSemver(pair[0])
+		w, _ := parseSemver(pair[1])
+		if got := v.compare(w); got != 0 {
+			t.Errorf("compare(%q, %q) = %d, want 0", pair[0], pair[1], got)
+		}
+	}
+}
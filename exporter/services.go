@@ -16,10 +16,12 @@ package exporter
 import (
 	"context"
 	"strconv"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
 
 	"github.com/coord-e/mirakurun_exporter/mirakurun"
 )
@@ -27,59 +29,258 @@ import (
 type servicesExporter struct {
 	ctx    context.Context
 	client *mirakurun.Client
+	source source
+	state  *State
 	logger log.Logger
 
-	grServices *prometheus.Desc
-	services   *prometheus.Desc
+	epgStaleThreshold time.Duration
+	epgOverdueCycles  int
+	networks          networkClassifier
+	filter            ServiceFilter
+
+	grServices          *prometheus.Desc
+	servicesByChannel   *prometheus.Desc
+	services            *prometheus.Desc
+	epgStaleServices    *prometheus.Desc
+	serviceEPGOverdue   *prometheus.Desc
+	serviceEPGOverdueBy *prometheus.Desc
+	serviceEPGReady     *prometheus.Desc
+	serviceEPGUpdatedAt *prometheus.Desc
+	serviceInfo         *prometheus.Desc
+	unknownNetworks     *prometheus.Desc
+
+	disabledChannelServices *prometheus.Desc
+	noChannelServices       *prometheus.Desc
+}
+
+type channelKey struct {
+	channelType string
+	channel     string
+	satellite   string
+	freq        string
+	polarity    string
 }
 
 // Verify if servicesExporter implements prometheus.Collector
 var _ prometheus.Collector = (*servicesExporter)(nil)
 
-func newServicesExporter(ctx context.Context, client *mirakurun.Client, logger log.Logger) *servicesExporter {
+func newServicesExporter(ctx context.Context, client *mirakurun.Client, config Config, state *State, logger log.Logger) *servicesExporter {
 	const subsystem = "services"
+	const serviceSubsystem = "service"
+
+	serviceLabels := []string{"service_id", "network_id", "network_kind", "name"}
 
 	return &servicesExporter{
 		ctx:    ctx,
 		client: client,
+		source: state.source(client),
+		state:  state,
 		logger: logger,
 
+		epgStaleThreshold: config.ServicesEPGStaleThreshold,
+		epgOverdueCycles:  config.ServicesEPGOverdueCycles,
+		networks:          newNetworkClassifier(config.NetworkKinds),
+		filter:            config.ServiceFilter,
+
 		grServices: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, subsystem, "GR_services"),
 			"Number of GR services available in Mirakurun.",
 			[]string{"channel"}, nil),
+		servicesByChannel: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "by_channel"),
+			"Number of services available in Mirakurun labeled by channel.",
+			[]string{"channel_type", "channel", "satellite", "freq", "polarity"}, nil),
 		services: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, subsystem, "services"),
 			"Number of all services available in Mirakurun.",
-			[]string{"network_id"}, nil),
+			[]string{"network_id", "network_kind"}, nil),
+		epgStaleServices: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "epg_stale_services"),
+			"Number of services whose EPG has not been updated within the threshold.",
+			[]string{"threshold"}, nil),
+		serviceEPGReady: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, serviceSubsystem, "epg_ready"),
+			"Whether EPG of the service is ready (1) or not (0).",
+			serviceLabels, nil),
+		serviceEPGUpdatedAt: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, serviceSubsystem, "epg_updated_timestamp_seconds"),
+			"Unix time when EPG of the service was last updated.",
+			serviceLabels, nil),
+		serviceEPGOverdue: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, serviceSubsystem, "epg_overdue"),
+			"Whether EPG of the service missed more gathering cycles than allowed (1) or not (0).",
+			serviceLabels, nil),
+		serviceEPGOverdueBy: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, serviceSubsystem, "epg_overdue_seconds"),
+			"Duration by which EPG update of the service is overdue in seconds, based on the configured gathering interval.",
+			serviceLabels, nil),
+		serviceInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, serviceSubsystem, "info"),
+			"A metric with a constant '1' value labeled by metadata of the service.",
+			[]string{"service_id", "network_id", "network_kind", "name", "type", "channel_type", "channel", "remote_control_key_id", "has_logo"}, nil),
+		unknownNetworks: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "unknown_networks_total"),
+			"Total number of distinct network IDs not found in the network kind table.",
+			nil, nil),
+		disabledChannelServices: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "disabled_channel_services"),
+			"Number of services whose channel is disabled in Mirakurun.",
+			[]string{"channel_type"}, nil),
+		noChannelServices: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "no_channel_services"),
+			"Number of services without channel information in Mirakurun.",
+			nil, nil),
 	}
 }
 
 func (e *servicesExporter) Describe(ch chan<- *prometheus.Desc) {
 	ch <- e.grServices
+	ch <- e.servicesByChannel
 	ch <- e.services
+	ch <- e.epgStaleServices
+	ch <- e.serviceEPGOverdue
+	ch <- e.serviceEPGOverdueBy
+	ch <- e.serviceEPGReady
+	ch <- e.serviceEPGUpdatedAt
+	ch <- e.serviceInfo
+	ch <- e.unknownNetworks
+	ch <- e.disabledChannelServices
+	ch <- e.noChannelServices
 }
 
 func (e *servicesExporter) Collect(ch chan<- prometheus.Metric) {
-	services, err := e.client.GetServices(e.ctx)
+	services, err := e.source.GetServices(e.ctx)
 	if err != nil {
 		level.Error(e.logger).Log("msg", "failed to fetch Mirakurun services", "err", err)
 		return
 	}
 
+	// EPG of a service is overdue when not updated within this allowance; zero disables the check
+	var epgOverdueAllowance time.Duration
+	if e.epgOverdueCycles > 0 {
+		epgOverdueAllowance = time.Duration(e.epgOverdueCycles) * e.epgGatheringInterval()
+	}
+
+	now := time.Now()
 	grCounts := map[string]int{}
+	channelCounts := map[channelKey]int{}
 	counts := map[int]int{}
-	for _, service := range *services {
-		if service.Channel != nil && service.Channel.Type == "GR" {
-			grCounts[service.Channel.Channel]++
+	disabledChannelServices := map[string]int{}
+	var epgStale, noChannel int
+	for i := range *services {
+		service := &(*services)[i]
+		if !e.filter.allows(serviceAttributesOf(service)) {
+			continue
+		}
+
+		if service.Channel == nil {
+			noChannel++
+		} else {
+			if service.Channel.Type == "GR" {
+				grCounts[service.Channel.Channel]++
+			}
+			if service.Channel.IsDisabled != nil && *service.Channel.IsDisabled {
+				disabledChannelServices[service.Channel.Type]++
+			}
+
+			key := channelKey{
+				channelType: service.Channel.Type,
+				channel:     service.Channel.Channel,
+			}
+			if service.Channel.Satelite != nil {
+				key.satellite = *service.Channel.Satelite
+			}
+			if service.Channel.Freq != nil {
+				key.freq = strconv.Itoa(*service.Channel.Freq)
+			}
+			if service.Channel.Polarity != nil {
+				key.polarity = *service.Channel.Polarity
+			}
+			channelCounts[key]++
 		}
 		counts[service.NetworkID]++
+
+		networkKind := e.networkKind(service.NetworkID)
+		name := e.state.labels.limit(serviceNameLimitKey, service.Name)
+		labels := []string{strconv.Itoa(service.ServiceID), strconv.Itoa(service.NetworkID), networkKind, name}
+
+		var channelType, channel, remoteControlKeyID string
+		if service.Channel != nil {
+			channelType = service.Channel.Type
+			channel = service.Channel.Channel
+		}
+		if service.RemoteControlKeyID != nil {
+			remoteControlKeyID = strconv.Itoa(*service.RemoteControlKeyID)
+		}
+		hasLogo := service.HasLogoData != nil && *service.HasLogoData
+		infoLabels := append(labels, serviceKind(service.Type), channelType, channel, remoteControlKeyID, strconv.FormatBool(hasLogo))
+		ch <- prometheus.MustNewConstMetric(e.serviceInfo, prometheus.UntypedValue, 1.0, infoLabels...)
+
+		if service.EpgReady != nil {
+			var ready float64
+			if *service.EpgReady {
+				ready = 1
+			}
+			ch <- prometheus.MustNewConstMetric(e.serviceEPGReady, prometheus.GaugeValue, ready, labels...)
+		}
+		if service.EpgUpdatedAt != nil {
+			// epgUpdatedAt is in milliseconds
+			updatedAt := time.UnixMilli(*service.EpgUpdatedAt)
+			ch <- prometheus.MustNewConstMetric(e.serviceEPGUpdatedAt, prometheus.GaugeValue, float64(*service.EpgUpdatedAt)/1000, labels...)
+			if now.Sub(updatedAt) > e.epgStaleThreshold {
+				epgStale++
+			}
+			if epgOverdueAllowance > 0 {
+				overdueBy := now.Sub(updatedAt) - epgOverdueAllowance
+				var overdue float64
+				if overdueBy > 0 {
+					overdue = 1
+				} else {
+					overdueBy = 0
+				}
+				ch <- prometheus.MustNewConstMetric(e.serviceEPGOverdue, prometheus.GaugeValue, overdue, labels...)
+				ch <- prometheus.MustNewConstMetric(e.serviceEPGOverdueBy, prometheus.GaugeValue, overdueBy.Seconds(), labels...)
+			}
+		}
 	}
 
 	for channel, count := range grCounts {
 		ch <- prometheus.MustNewConstMetric(e.grServices, prometheus.GaugeValue, float64(count), channel)
 	}
+	for key, count := range channelCounts {
+		ch <- prometheus.MustNewConstMetric(e.servicesByChannel, prometheus.GaugeValue, float64(count), key.channelType, key.channel, key.satellite, key.freq, key.polarity)
+	}
 	for networkID, count := range counts {
-		ch <- prometheus.MustNewConstMetric(e.services, prometheus.GaugeValue, float64(count), strconv.Itoa(networkID))
+		ch <- prometheus.MustNewConstMetric(e.services, prometheus.GaugeValue, float64(count), strconv.Itoa(networkID), e.networkKind(networkID))
+	}
+	for channelType, count := range disabledChannelServices {
+		ch <- prometheus.MustNewConstMetric(e.disabledChannelServices, prometheus.GaugeValue, float64(count), channelType)
+	}
+	ch <- prometheus.MustNewConstMetric(e.noChannelServices, prometheus.GaugeValue, float64(noChannel))
+	ch <- prometheus.MustNewConstMetric(e.epgStaleServices, prometheus.GaugeValue, float64(epgStale), model.Duration(e.epgStaleThreshold).String())
+	ch <- prometheus.MustNewConstMetric(e.unknownNetworks, prometheus.CounterValue, float64(e.state.unknownNetworks.count()))
+}
+
+// epgGatheringInterval returns the EPG gathering interval configured in Mirakurun, or zero if it is not available.
+func (e *servicesExporter) epgGatheringInterval() time.Duration {
+	interval, err := e.state.epgInterval.get(func(ctx context.Context) (time.Duration, error) {
+		config, err := e.client.GetServerConfig(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if config.EPGGatheringInterval == nil {
+			level.Warn(e.logger).Log("msg", "EPG gathering interval is not available in Mirakurun server config")
+			return 0, nil
+		}
+		// epgGatheringInterval is in milliseconds
+		return time.Duration(*config.EPGGatheringInterval) * time.Millisecond, nil
+	})
+	if err != nil {
+		level.Error(e.logger).Log("msg", "failed to fetch Mirakurun server config", "err", err)
 	}
+	return interval
+}
+
+func (e *servicesExporter) networkKind(networkID int) string {
+	return classifyNetwork(e.networks, e.state, e.logger, networkID)
 }
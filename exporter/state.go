@@ -0,0 +1,179 @@
+// Copyright 2021 coord_e
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//  	 http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/coord-e/mirakurun_exporter/mirakurun"
+)
+
+// State holds data tracked across scrapes.
+// Exporter is created for each scrape, so a single State should be shared among them.
+type State struct {
+	epgGathering    *epgGatheringTracker
+	unknownNetworks *unknownNetworkTracker
+	labels          *labelLimiter
+	events          *eventTracker
+	mirror          *mirror
+	epgInterval     *fetchCache[time.Duration]
+	version         *fetchCache[*mirakurun.VersionResponse]
+}
+
+func NewState(config Config) *State {
+	var mirror *mirror
+	if config.Mirror {
+		mirror = newMirror(config.MirrorResyncInterval, config.MirrorTunersPollInterval)
+	}
+
+	return &State{
+		epgGathering:    newEPGGatheringTracker(),
+		unknownNetworks: newUnknownNetworkTracker(),
+		labels:          newLabelLimiter(config.LabelLimit, config.LabelMaxLength),
+		events:          newEventTracker(),
+		mirror:          mirror,
+		epgInterval:     newFetchCache[time.Duration](epgGatheringIntervalRefresh),
+		version:         newFetchCache[*mirakurun.VersionResponse](versionRefresh),
+	}
+}
+
+type epgGatheringNetwork struct {
+	gathering     bool
+	cycles        int
+	lastCompleted time.Time
+}
+
+type epgGatheringTracker struct {
+	mu       sync.Mutex
+	networks map[int64]*epgGatheringNetwork
+}
+
+func newEPGGatheringTracker() *epgGatheringTracker {
+	return &epgGatheringTracker{
+		networks: map[int64]*epgGatheringNetwork{},
+	}
+}
+
+// observe records networks currently gathering EPG and returns a snapshot of all known networks.
+// A network which was gathering in the previous observation but not anymore is considered to have completed a cycle.
+func (t *epgGatheringTracker) observe(gathering []int64, now time.Time) map[int64]epgGatheringNetwork {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	current := map[int64]bool{}
+	for _, networkID := range gathering {
+		current[networkID] = true
+		if _, ok := t.networks[networkID]; !ok {
+			t.networks[networkID] = &epgGatheringNetwork{}
+		}
+	}
+
+	snapshot := make(map[int64]epgGatheringNetwork, len(t.networks))
+	for networkID, network := range t.networks {
+		if network.gathering && !current[networkID] {
+			network.cycles++
+			network.lastCompleted = now
+		}
+		network.gathering = current[networkID]
+		snapshot[networkID] = *network
+	}
+
+	return snapshot
+}
+
+type unknownNetworkTracker struct {
+	mu       sync.Mutex
+	networks map[int]struct{}
+}
+
+func newUnknownNetworkTracker() *unknownNetworkTracker {
+	return &unknownNetworkTracker{
+		networks: map[int]struct{}{},
+	}
+}
+
+// observe records a network ID not found in the network kind table and reports whether it is seen for the first time.
+func (t *unknownNetworkTracker) observe(networkID int) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.networks[networkID]; ok {
+		return false
+	}
+	t.networks[networkID] = struct{}{}
+	return true
+}
+
+func (t *unknownNetworkTracker) count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.networks)
+}
+
+// epgGatheringIntervalRefresh is the interval to refetch the server config for the EPG gathering interval,
+// which rarely changes and is not worth fetching on every scrape.
+const epgGatheringIntervalRefresh = 10 * time.Minute
+
+// versionRefresh is the interval to refetch the version of Mirakurun,
+// as Mirakurun queries npm for the latest version on each request to /api/version.
+const versionRefresh = time.Hour
+
+// cachedFetchTimeout bounds a fetch of a cached value, which is not tied to the scrape triggering it.
+const cachedFetchTimeout = 10 * time.Second
+
+// cachedFetchRetryInterval is the interval to retry fetching a cached value after a failure.
+const cachedFetchRetryInterval = time.Minute
+
+// fetchCache holds a value fetched from Mirakurun for a while, for data which rarely changes or is costly to fetch.
+type fetchCache[T any] struct {
+	refresh time.Duration
+	now     func() time.Time
+
+	mu        sync.Mutex
+	value     T
+	nextFetch time.Time
+}
+
+func newFetchCache[T any](refresh time.Duration) *fetchCache[T] {
+	return &fetchCache[T]{refresh: refresh, now: time.Now}
+}
+
+// get returns the cached value, calling fetch to update it when the cache is due.
+// The value is held for the refresh interval only after a successful fetch, and the fetch is retried
+// after cachedFetchRetryInterval on failure. fetch is called with its own timeout rather than the context
+// of the scrape so that a cancelled scrape does not result in a failure.
+// On failure, the error is returned along with the last known value, which is the zero value until fetch succeeds.
+func (c *fetchCache[T]) get(fetch func(context.Context) (T, error)) (T, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if now.Before(c.nextFetch) {
+		return c.value, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cachedFetchTimeout)
+	defer cancel()
+	value, err := fetch(ctx)
+	if err != nil {
+		c.nextFetch = now.Add(cachedFetchRetryInterval)
+		return c.value, err
+	}
+	c.value = value
+	c.nextFetch = now.Add(c.refresh)
+	return c.value, nil
+}
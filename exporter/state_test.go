@@ -0,0 +1,80 @@
+// Copyright 2021 coord_e
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//  	 http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFetchCache(t *testing.T) {
+	type step struct {
+		after     time.Duration
+		fetchErr  error
+		wantFetch bool
+		want      int
+		wantErr   bool
+	}
+	errFetch := errors.New("fetch failed")
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "held after success",
+			steps: []step{
+				{wantFetch: true, want: 1},
+				{after: time.Hour - time.Second, want: 1},
+				{after: time.Second, wantFetch: true, want: 2},
+			},
+		},
+		{
+			name: "retried after failure",
+			steps: []step{
+				{fetchErr: errFetch, wantFetch: true, want: 0, wantErr: true},
+				{after: cachedFetchRetryInterval - time.Second, want: 0},
+				{after: time.Second, wantFetch: true, want: 1},
+				{after: time.Hour, fetchErr: errFetch, wantFetch: true, want: 1, wantErr: true},
+				{after: cachedFetchRetryInterval, wantFetch: true, want: 2},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		now := time.Unix(0, 0)
+		c := newFetchCache[int](time.Hour)
+		c.now = func() time.Time { return now }
+		var fetches int
+		for i, s := range tt.steps {
+			now = now.Add(s.after)
+			fetched := false
+			got, err := c.get(func(ctx context.Context) (int, error) {
+				fetched = true
+				if s.fetchErr != nil {
+					return 0, s.fetchErr
+				}
+				fetches++
+				return fetches, nil
+			})
+			if fetched != s.wantFetch {
+				t.Errorf("%s: step %d: fetched = %v, want %v", tt.name, i, fetched, s.wantFetch)
+			}
+			if got != s.want || (err != nil) != s.wantErr {
+				t.Errorf("%s: step %d: get() = (%d, %v), want %d and error: %v", tt.name, i, got, err, s.want, s.wantErr)
+			}
+		}
+	}
+}
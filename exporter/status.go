@@ -15,6 +15,8 @@ package exporter
 
 import (
 	"context"
+	"strconv"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -26,11 +28,14 @@ import (
 type statusExporter struct {
 	ctx    context.Context
 	client *mirakurun.Client
+	state  *State
 	logger log.Logger
 
 	residentMemory   *prometheus.Desc
 	totalMemory      *prometheus.Desc
 	usedMemory       *prometheus.Desc
+	memory           *prometheus.Desc
+	heapUtilization  *prometheus.Desc
 	programsDBEvents *prometheus.Desc
 	rpcConnections   *prometheus.Desc
 	streams          *prometheus.Desc
@@ -39,17 +44,25 @@ type statusExporter struct {
 	timerError5      *prometheus.Desc
 	timerError15     *prometheus.Desc
 	info             *prometheus.Desc
+	runtimeVersions  *prometheus.Desc
+
+	epgGatheringNetworks      *prometheus.Desc
+	epgGathering              *prometheus.Desc
+	epgGatheringCycles        *prometheus.Desc
+	epgGatheringLastCompleted *prometheus.Desc
 }
 
 // Verify if statusExporter implements prometheus.Collector
 var _ prometheus.Collector = (*statusExporter)(nil)
 
-func newStatusExporter(ctx context.Context, client *mirakurun.Client, logger log.Logger) *statusExporter {
+func newStatusExporter(ctx context.Context, client *mirakurun.Client, state *State, logger log.Logger) *statusExporter {
 	const subsystem = "status"
+	const epgSubsystem = "epg"
 
 	return &statusExporter{
 		ctx:    ctx,
 		client: client,
+		state:  state,
 		logger: logger,
 
 		residentMemory: prometheus.NewDesc(
@@ -66,6 +79,16 @@ func newStatusExporter(ctx context.Context, client *mirakurun.Client, logger log
 			"Used heap size of the Mirakurun process in bytes.",
 			nil,
 			nil),
+		memory: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "memory_bytes"),
+			"Memory usage of the Mirakurun process in bytes labeled by type as reported by Node.js.",
+			[]string{"type"},
+			nil),
+		heapUtilization: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "heap_utilization_ratio"),
+			"Ratio of used heap size to total heap size of the Mirakurun process.",
+			nil,
+			nil),
 		programsDBEvents: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, subsystem, "programs_db_events"),
 			"Number of EPG programs stored in Mirakurun.",
@@ -104,7 +127,33 @@ func newStatusExporter(ctx context.Context, client *mirakurun.Client, logger log
 		info: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, subsystem, "info"),
 			"A metric with a constant '1' value labeled by metadata of Mirakurun.",
-			[]string{"nodeversion", "version", "arch"},
+			[]string{"nodeversion", "version", "arch", "platform", "version_major", "version_minor", "version_prerelease"},
+			nil),
+		runtimeVersions: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "runtime_versions_info"),
+			"A metric with a constant '1' value labeled by versions of components in the Mirakurun runtime.",
+			[]string{"component", "version"},
+			nil),
+
+		epgGatheringNetworks: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, epgSubsystem, "gathering_networks"),
+			"Number of networks Mirakurun is gathering EPG from.",
+			nil,
+			nil),
+		epgGathering: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, epgSubsystem, "gathering"),
+			"Whether Mirakurun is gathering EPG from the network (1) or not (0).",
+			[]string{"network_id"},
+			nil),
+		epgGatheringCycles: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, epgSubsystem, "gathering_cycles_total"),
+			"Total number of EPG gathering cycles completed for the network observed by the exporter.",
+			[]string{"network_id"},
+			nil),
+		epgGatheringLastCompleted: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, epgSubsystem, "gathering_last_completed_timestamp_seconds"),
+			"Unix time when the last EPG gathering cycle for the network was observed to complete.",
+			[]string{"network_id"},
 			nil),
 	}
 }
@@ -113,6 +162,8 @@ func (e *statusExporter) Describe(ch chan<- *prometheus.Desc) {
 	ch <- e.residentMemory
 	ch <- e.totalMemory
 	ch <- e.usedMemory
+	ch <- e.memory
+	ch <- e.heapUtilization
 	ch <- e.programsDBEvents
 	ch <- e.rpcConnections
 	ch <- e.streams
@@ -121,6 +172,11 @@ func (e *statusExporter) Describe(ch chan<- *prometheus.Desc) {
 	ch <- e.timerError5
 	ch <- e.timerError15
 	ch <- e.info
+	ch <- e.runtimeVersions
+	ch <- e.epgGatheringNetworks
+	ch <- e.epgGathering
+	ch <- e.epgGatheringCycles
+	ch <- e.epgGatheringLastCompleted
 }
 
 func (e *statusExporter) Collect(ch chan<- prometheus.Metric) {
@@ -133,6 +189,15 @@ func (e *statusExporter) Collect(ch chan<- prometheus.Metric) {
 	ch <- prometheus.MustNewConstMetric(e.residentMemory, prometheus.GaugeValue, float64(status.Process.MemoryUsage.RSS))
 	ch <- prometheus.MustNewConstMetric(e.totalMemory, prometheus.GaugeValue, float64(status.Process.MemoryUsage.HeapTotal))
 	ch <- prometheus.MustNewConstMetric(e.usedMemory, prometheus.GaugeValue, float64(status.Process.MemoryUsage.HeapUsed))
+	ch <- prometheus.MustNewConstMetric(e.memory, prometheus.GaugeValue, float64(status.Process.MemoryUsage.RSS), "rss")
+	ch <- prometheus.MustNewConstMetric(e.memory, prometheus.GaugeValue, float64(status.Process.MemoryUsage.HeapTotal), "heap_total")
+	ch <- prometheus.MustNewConstMetric(e.memory, prometheus.GaugeValue, float64(status.Process.MemoryUsage.HeapUsed), "heap_used")
+	ch <- prometheus.MustNewConstMetric(e.memory, prometheus.GaugeValue, float64(status.Process.MemoryUsage.External), "external")
+	ch <- prometheus.MustNewConstMetric(e.memory, prometheus.GaugeValue, float64(status.Process.MemoryUsage.ArrayBuffers), "array_buffers")
+	if status.Process.MemoryUsage.HeapTotal > 0 {
+		ratio := float64(status.Process.MemoryUsage.HeapUsed) / float64(status.Process.MemoryUsage.HeapTotal)
+		ch <- prometheus.MustNewConstMetric(e.heapUtilization, prometheus.GaugeValue, ratio)
+	}
 	ch <- prometheus.MustNewConstMetric(e.programsDBEvents, prometheus.GaugeValue, float64(status.EPG.StoredEvents))
 	if status.RPCCount != nil {
 		ch <- prometheus.MustNewConstMetric(e.rpcConnections, prometheus.GaugeValue, float64(*status.RPCCount))
@@ -148,5 +213,31 @@ func (e *statusExporter) Collect(ch chan<- prometheus.Metric) {
 	ch <- prometheus.MustNewConstMetric(e.timerError1, prometheus.GaugeValue, status.TimerAccuracy.M1.Avg/1000000)
 	ch <- prometheus.MustNewConstMetric(e.timerError5, prometheus.GaugeValue, status.TimerAccuracy.M5.Avg/1000000)
 	ch <- prometheus.MustNewConstMetric(e.timerError15, prometheus.GaugeValue, status.TimerAccuracy.M15.Avg/1000000)
-	ch <- prometheus.MustNewConstMetric(e.info, prometheus.UntypedValue, 1.0, status.Process.Versions["node"], status.Version, status.Process.Arch)
+
+	var major, minor, prerelease string
+	if v, ok := parseSemver(status.Version); ok {
+		major = strconv.Itoa(v.major)
+		minor = strconv.Itoa(v.minor)
+		prerelease = v.prerelease
+	} else {
+		level.Warn(e.logger).Log("msg", "unable to parse Mirakurun version", "version", status.Version)
+	}
+	ch <- prometheus.MustNewConstMetric(e.info, prometheus.UntypedValue, 1.0, status.Process.Versions["node"], status.Version, status.Process.Arch, status.Process.Platform, major, minor, prerelease)
+	for component, version := range status.Process.Versions {
+		ch <- prometheus.MustNewConstMetric(e.runtimeVersions, prometheus.UntypedValue, 1.0, component, version)
+	}
+
+	ch <- prometheus.MustNewConstMetric(e.epgGatheringNetworks, prometheus.GaugeValue, float64(len(status.EPG.GatheringNetworks)))
+	for networkID, network := range e.state.epgGathering.observe(status.EPG.GatheringNetworks, time.Now()) {
+		id := strconv.FormatInt(networkID, 10)
+		var gathering float64
+		if network.gathering {
+			gathering = 1
+		}
+		ch <- prometheus.MustNewConstMetric(e.epgGathering, prometheus.GaugeValue, gathering, id)
+		ch <- prometheus.MustNewConstMetric(e.epgGatheringCycles, prometheus.CounterValue, float64(network.cycles), id)
+		if !network.lastCompleted.IsZero() {
+			ch <- prometheus.MustNewConstMetric(e.epgGatheringLastCompleted, prometheus.GaugeValue, float64(network.lastCompleted.Unix()), id)
+		}
+	}
 }
@@ -26,8 +26,12 @@ import (
 type tunersExporter struct {
 	ctx    context.Context
 	client *mirakurun.Client
+	source source
+	state  *State
 	logger log.Logger
 
+	filter ServiceFilter
+
 	availableTunerDevices *prometheus.Desc
 	faultTunerDevices     *prometheus.Desc
 	remoteTunerDevices    *prometheus.Desc
@@ -37,6 +41,7 @@ type tunersExporter struct {
 	skyTunerDevices       *prometheus.Desc
 	tunerDevices          *prometheus.Desc
 	users                 *prometheus.Desc
+	disabledChannelUsers  *prometheus.Desc
 	streamDrops           *prometheus.Desc
 	streamPackets         *prometheus.Desc
 }
@@ -44,14 +49,18 @@ type tunersExporter struct {
 // Verify if tunersExporter implements prometheus.Collector
 var _ prometheus.Collector = (*tunersExporter)(nil)
 
-func newTunersExporter(ctx context.Context, client *mirakurun.Client, logger log.Logger) *tunersExporter {
+func newTunersExporter(ctx context.Context, client *mirakurun.Client, config Config, state *State, logger log.Logger) *tunersExporter {
 	const subsystem = "tuners"
 
 	return &tunersExporter{
 		ctx:    ctx,
 		client: client,
+		source: state.source(client),
+		state:  state,
 		logger: logger,
 
+		filter: config.ServiceFilter,
+
 		availableTunerDevices: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, subsystem, "available_tuner_devices"),
 			"Number of available tuner devices in Mirakurun.",
@@ -88,6 +97,10 @@ func newTunersExporter(ctx context.Context, client *mirakurun.Client, logger log
 			prometheus.BuildFQName(namespace, subsystem, "users"),
 			"Number of tuner users in Mirakurun labeled by tuner device name.",
 			[]string{"tuner_device"}, nil),
+		disabledChannelUsers: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, subsystem, "disabled_channel_users"),
+			"Number of tuner users streaming a disabled channel in Mirakurun labeled by tuner device name.",
+			[]string{"tuner_device"}, nil),
 		streamDrops: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, subsystem, "stream_drops_total"),
 			"Total number of drops in a TS stream of Mirakurun labeled by tuner device name.",
@@ -109,19 +122,35 @@ func (e *tunersExporter) Describe(ch chan<- *prometheus.Desc) {
 	ch <- e.skyTunerDevices
 	ch <- e.tunerDevices
 	ch <- e.users
+	ch <- e.disabledChannelUsers
 	ch <- e.streamDrops
 	ch <- e.streamPackets
 }
 
 func (e *tunersExporter) Collect(ch chan<- prometheus.Metric) {
-	tuners, err := e.client.GetTuners(e.ctx)
+	tuners, err := e.source.GetTuners(e.ctx)
 	if err != nil {
 		level.Error(e.logger).Log("msg", "failed to fetch Mirakurun tuners", "err", err)
 		return
 	}
 
+	// users are matched against the filter by attributes of the service they are streaming
+	serviceAttrs := map[[2]int]serviceAttributes{}
+	if !e.filter.empty() {
+		services, err := e.source.GetServices(e.ctx)
+		if err != nil {
+			level.Error(e.logger).Log("msg", "failed to fetch Mirakurun services", "err", err)
+			return
+		}
+		for i := range *services {
+			service := &(*services)[i]
+			serviceAttrs[[2]int{service.NetworkID, service.ServiceID}] = serviceAttributesOf(service)
+		}
+	}
+
 	var availableFree, availableUsed, fault, remote, gr, bs, cs, sky int
-	users := map[string]int{}
+	users := map[string]int64{}
+	disabledChannelUsers := map[string]int64{}
 	drops := map[string]int64{}
 	packets := map[string]int64{}
 	for _, tuner := range *tuners {
@@ -152,10 +181,27 @@ func (e *tunersExporter) Collect(ch chan<- prometheus.Metric) {
 			}
 		}
 		users[tuner.Name] = 0
+		disabledChannelUsers[tuner.Name] = 0
 		drops[tuner.Name] = 0
 		packets[tuner.Name] = 0
 		for _, user := range tuner.Users {
 			users[tuner.Name]++
+			if setting := user.StreamSetting; setting != nil && setting.Channel.IsDisabled != nil && *setting.Channel.IsDisabled {
+				var allowed bool
+				if setting.ServiceID != nil && setting.NetworkID != nil {
+					attrs, ok := serviceAttrs[[2]int{*setting.NetworkID, *setting.ServiceID}]
+					if !ok {
+						attrs = serviceAttributes{serviceID: *setting.ServiceID, networkID: *setting.NetworkID, channelType: setting.Channel.Type}
+					}
+					allowed = e.filter.allows(attrs)
+				} else {
+					// the user streams a whole channel
+					allowed = e.filter.allowsChannelType(setting.Channel.Type)
+				}
+				if allowed {
+					disabledChannelUsers[tuner.Name]++
+				}
+			}
 
 			if user.StreamInfo == nil {
 				continue
@@ -176,13 +222,16 @@ func (e *tunersExporter) Collect(ch chan<- prometheus.Metric) {
 	ch <- prometheus.MustNewConstMetric(e.csTunerDevices, prometheus.GaugeValue, float64(cs))
 	ch <- prometheus.MustNewConstMetric(e.skyTunerDevices, prometheus.GaugeValue, float64(sky))
 	ch <- prometheus.MustNewConstMetric(e.tunerDevices, prometheus.GaugeValue, float64(len(*tuners)))
-	for tunerDevice, count := range users {
+	for tunerDevice, count := range e.state.labels.limitCounts(prometheus.BuildFQName(namespace, "tuners", "users"), users) {
 		ch <- prometheus.MustNewConstMetric(e.users, prometheus.GaugeValue, float64(count), tunerDevice)
 	}
-	for tunerDevice, count := range drops {
+	for tunerDevice, count := range e.state.labels.limitCounts(prometheus.BuildFQName(namespace, "tuners", "disabled_channel_users"), disabledChannelUsers) {
+		ch <- prometheus.MustNewConstMetric(e.disabledChannelUsers, prometheus.GaugeValue, float64(count), tunerDevice)
+	}
+	for tunerDevice, count := range e.state.labels.limitCounters(prometheus.BuildFQName(namespace, "tuners", "stream_drops_total"), drops) {
 		ch <- prometheus.MustNewConstMetric(e.streamDrops, prometheus.CounterValue, float64(count), tunerDevice)
 	}
-	for tunerDevice, count := range packets {
+	for tunerDevice, count := range e.state.labels.limitCounters(prometheus.BuildFQName(namespace, "tuners", "stream_packets_total"), packets) {
 		ch <- prometheus.MustNewConstMetric(e.streamPackets, prometheus.CounterValue, float64(count), tunerDevice)
 	}
 }
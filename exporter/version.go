@@ -0,0 +1,86 @@
+// Copyright 2021 coord_e
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//  	 http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package exporter
+
+import (
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/coord-e/mirakurun_exporter/mirakurun"
+)
+
+type versionExporter struct {
+	client *mirakurun.Client
+	state  *State
+	logger log.Logger
+
+	info            *prometheus.Desc
+	updateAvailable *prometheus.Desc
+}
+
+// Verify if versionExporter implements prometheus.Collector
+var _ prometheus.Collector = (*versionExporter)(nil)
+
+func newVersionExporter(client *mirakurun.Client, state *State, logger log.Logger) *versionExporter {
+	return &versionExporter{
+		client: client,
+		state:  state,
+		logger: logger,
+
+		info: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "version", "info"),
+			"A metric with a constant '1' value labeled by the current and the latest version of Mirakurun.",
+			[]string{"current", "latest"}, nil),
+		updateAvailable: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "update_available"),
+			"Whether the latest version of Mirakurun is newer than the current one (1) or not (0).",
+			nil, nil),
+	}
+}
+
+func (e *versionExporter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- e.info
+	ch <- e.updateAvailable
+}
+
+func (e *versionExporter) Collect(ch chan<- prometheus.Metric) {
+	// the version is cached as Mirakurun queries npm on each request
+	version, err := e.state.version.get(e.client.GetVersion)
+	if err != nil {
+		level.Error(e.logger).Log("msg", "failed to fetch Mirakurun version", "err", err)
+	}
+	if version == nil {
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(e.info, prometheus.UntypedValue, 1.0, version.Current, version.Latest)
+
+	current, ok := parseSemver(version.Current)
+	if !ok {
+		level.Warn(e.logger).Log("msg", "unable to parse current Mirakurun version", "version", version.Current)
+		return
+	}
+	latest, ok := parseSemver(version.Latest)
+	if !ok {
+		level.Warn(e.logger).Log("msg", "unable to parse latest Mirakurun version", "version", version.Latest)
+		return
+	}
+
+	var updateAvailable float64
+	if latest.compare(current) > 0 {
+		updateAvailable = 1
+	}
+	ch <- prometheus.MustNewConstMetric(e.updateAvailable, prometheus.GaugeValue, updateAvailable)
+}
@@ -14,6 +14,7 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	"os"
 	"time"
@@ -51,6 +52,52 @@ var (
 		"Whether to export metrics from /api/programs.").Default("true").Bool()
 	fetchServices = kingpin.Flag("exporter.services",
 		"Whether to export metrics from /api/services.").Default("true").Bool()
+	fetchChannels = kingpin.Flag("exporter.channels",
+		"Whether to export metrics from /api/channels.").Default("true").Bool()
+	fetchConfig = kingpin.Flag("exporter.config",
+		"Whether to export metrics from /api/config.").Default("true").Bool()
+	fetchVersion = kingpin.Flag("exporter.version",
+		"Whether to export metrics from /api/version, which is fetched at most once an hour.").Default("true").Bool()
+	fetchEvents = kingpin.Flag("exporter.events",
+		"Whether to subscribe to /api/events/stream and export metrics from it.").Default("false").Bool()
+	eventsResource = kingpin.Flag("exporter.events.resource",
+		"Resource of events to subscribe to. All resources if empty.").Default("").Enum("", "program", "service", "tuner")
+	eventsType = kingpin.Flag("exporter.events.type",
+		"Type of events to subscribe to. All types if empty.").Default("").Enum("", "create", "update", "redefine", "remove")
+	mirror = kingpin.Flag("exporter.mirror",
+		"Whether to keep an in-memory mirror of programs, services and tuners updated by /api/events/stream instead of fetching them on every scrape. Filters of --exporter.events are ignored if enabled.").Default("false").Bool()
+	mirrorResyncInterval = kingpin.Flag("exporter.mirror.resync-interval",
+		"Interval to fully reload the in-memory mirror and measure its divergence from Mirakurun. 0 disables periodic reloads.").Default("1h").Duration()
+	mirrorTunersPollInterval = kingpin.Flag("exporter.mirror.tuners-poll-interval",
+		"Interval to reload tuners in the in-memory mirror to update their stream counters, which change without events. 0 disables polling.").Default("15s").Duration()
+	servicesEPGStaleThreshold = kingpin.Flag("exporter.services.epg-stale-threshold",
+		"Duration after which EPG of a service is considered stale.").Default("6h").Duration()
+	servicesEPGOverdueCycles = kingpin.Flag("exporter.services.epg-overdue-cycles",
+		"Number of EPG gathering cycles configured in Mirakurun a service may miss before its EPG is considered overdue. 0 disables the check.").Default("2").Int()
+	serviceNameLabel = kingpin.Flag("exporter.service-name-label",
+		"Whether to attach service_name label to per-service metrics. This requires fetching /api/services.").Default("false").Bool()
+	networkKinds = kingpin.Flag("exporter.network-kind",
+		"Kind of networks in the form of FROM[-TO]=KIND, overriding the built-in table. Repeatable.").Strings()
+	programsWindows = kingpin.Flag("exporter.programs.window",
+		"Window from now to count upcoming programs in. Repeatable.").Default("1h", "1d", "7d").Strings()
+	programsScheduleWindow = kingpin.Flag("exporter.programs.schedule-window",
+		"Window from now to detect gaps and overlaps between programs in.").Default("24h").Duration()
+	programsCurrentInfo = kingpin.Flag("exporter.programs.current-info",
+		"Whether to export names of programs currently on air. This may increase cardinality.").Default("false").Bool()
+	programsGenreAggregate = kingpin.Flag("exporter.programs.genre-aggregate",
+		"Whether to aggregate the number of programs by genre over all services.").Default("false").Bool()
+	programsSeriesExpiryThreshold = kingpin.Flag("exporter.programs.series-expiry-threshold",
+		"Duration before expiry within which a series is considered expiring.").Default("168h").Duration()
+	programsServiceIDs = kingpin.Flag("exporter.programs.service-id",
+		"Service ID to fetch programs of. All programs are fetched if not specified. Repeatable.").Ints()
+	serviceIncludes = kingpin.Flag("exporter.service.include",
+		"Export metrics only of services matching FIELD=VALUE, where FIELD is one of service_id, network_id, channel_type, service_type and name (regular expression). Repeatable.").Strings()
+	serviceExcludes = kingpin.Flag("exporter.service.exclude",
+		"Do not export metrics of services matching FIELD=VALUE in the same form as --exporter.service.include. Repeatable.").Strings()
+	labelLimit = kingpin.Flag("exporter.label-limit",
+		"Maximum number of distinct values of a label per metric. Excess values are replaced with __other__ or dropped for counters, and values not seen for an hour give their place to new ones. 0 means unlimited.").Default("1000").Int()
+	labelMaxLength = kingpin.Flag("exporter.label-max-length",
+		"Maximum length of label values in characters. Longer values are truncated. 0 means unlimited.").Default("128").Int()
 )
 
 func main() {
@@ -68,16 +115,104 @@ func main() {
 		level.Error(logger).Log("msg", "failed to create Mirakurun client", "err", err)
 		os.Exit(1)
 	}
+	client.Logger = logger
+
+	var networkKindRanges []exporter.NetworkKindRange
+	for _, s := range *networkKinds {
+		r, err := exporter.ParseNetworkKindRange(s)
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to parse network kind", "err", err)
+			os.Exit(1)
+		}
+		networkKindRanges = append(networkKindRanges, r)
+	}
+
+	var serviceFilter exporter.ServiceFilter
+	for _, s := range *serviceIncludes {
+		m, err := exporter.ParseServiceMatcher(s)
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to parse service include rule", "err", err)
+			os.Exit(1)
+		}
+		serviceFilter.Include = append(serviceFilter.Include, m)
+	}
+	for _, s := range *serviceExcludes {
+		m, err := exporter.ParseServiceMatcher(s)
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to parse service exclude rule", "err", err)
+			os.Exit(1)
+		}
+		serviceFilter.Exclude = append(serviceFilter.Exclude, m)
+	}
+
+	var windows []exporter.ProgramsWindow
+	windowDurations := map[time.Duration]bool{}
+	for _, s := range *programsWindows {
+		w, err := exporter.ParseProgramsWindow(s)
+		if err != nil {
+			level.Error(logger).Log("msg", "failed to parse programs window", "err", err)
+			os.Exit(1)
+		}
+		// the same window would be exported twice, even when written differently as 1d and 24h
+		if windowDurations[w.Duration] {
+			level.Error(logger).Log("msg", "duplicate programs window", "window", s)
+			os.Exit(1)
+		}
+		windowDurations[w.Duration] = true
+		windows = append(windows, w)
+	}
+
+	if *mirrorResyncInterval < 0 {
+		level.Error(logger).Log("msg", "mirror resync interval must not be negative", "interval", *mirrorResyncInterval)
+		os.Exit(1)
+	}
+	if *mirrorTunersPollInterval < 0 {
+		level.Error(logger).Log("msg", "mirror tuners poll interval must not be negative", "interval", *mirrorTunersPollInterval)
+		os.Exit(1)
+	}
 
 	config := exporter.Config{
 		FetchStatus:   *fetchStatus,
 		FetchTuners:   *fetchTuners,
 		FetchPrograms: *fetchPrograms,
 		FetchServices: *fetchServices,
+		FetchChannels: *fetchChannels,
+		FetchConfig:   *fetchConfig,
+		FetchVersion:  *fetchVersion,
+		FetchEvents:   *fetchEvents,
+
+		Mirror:                   *mirror,
+		MirrorResyncInterval:     *mirrorResyncInterval,
+		MirrorTunersPollInterval: *mirrorTunersPollInterval,
+
+		ServicesEPGStaleThreshold: *servicesEPGStaleThreshold,
+		ServicesEPGOverdueCycles:  *servicesEPGOverdueCycles,
+		ServiceNameLabel:          *serviceNameLabel,
+		NetworkKinds:              networkKindRanges,
+		ProgramsWindows:           windows,
+		ProgramsScheduleWindow:    *programsScheduleWindow,
+		ProgramsCurrentInfo:       *programsCurrentInfo,
+		ProgramsGenreAggregate:    *programsGenreAggregate,
+
+		ProgramsSeriesExpiryThreshold: *programsSeriesExpiryThreshold,
+		ProgramsServiceIDs:            *programsServiceIDs,
+
+		ServiceFilter: serviceFilter,
+
+		LabelLimit:     *labelLimit,
+		LabelMaxLength: *labelMaxLength,
+	}
+	state := exporter.NewState(config)
+	if config.Mirror {
+		// the mirror subscribes to all events, which are shared with the events collector
+		go exporter.RunMirror(context.Background(), client, state, logger)
+	} else if config.FetchEvents {
+		query := &mirakurun.EventsQuery{Resource: *eventsResource, Type: *eventsType}
+		go exporter.RunEventSubscriber(context.Background(), client, query, state, logger)
 	}
 	var handler http.HandlerFunc = func(w http.ResponseWriter, r *http.Request) {
 		registry := prometheus.NewRegistry()
-		exporter := exporter.New(r.Context(), client, config, logger)
+		exporter := exporter.New(r.Context(), client, config, state, logger)
 		registry.MustRegister(exporter)
 		h := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
 		h.ServeHTTP(w, r)
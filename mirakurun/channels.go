@@ -0,0 +1,94 @@
+// Copyright 2021 coord_e
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//  	 http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirakurun
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+)
+
+type Channel struct {
+	Type       string  `json:"type"`
+	Channel    string  `json:"channel"`
+	Name       string  `json:"name"`
+	Satelite   *string `json:"satelite"`
+	Space      *int    `json:"space"`
+	Freq       *int    `json:"freq"`
+	Polarity   *string `json:"polarity"`
+	TSMFRelTS  *int    `json:"tsmfRelTs"`
+	IsDisabled *bool   `json:"isDisabled"`
+	Services   []struct {
+		ID        int64  `json:"id"`
+		ServiceID int    `json:"serviceId"`
+		NetworkID int    `json:"networkId"`
+		Name      string `json:"name"`
+	} `json:"services"`
+}
+
+type ChannelsResponse []Channel
+
+func (c *Client) GetChannels(ctx context.Context) (*ChannelsResponse, error) {
+	return c.getChannels(ctx, "/api/channels")
+}
+
+func (c *Client) GetChannelsByType(ctx context.Context, channelType string) (*ChannelsResponse, error) {
+	return c.getChannels(ctx, "/api/channels/"+url.PathEscape(channelType))
+}
+
+func (c *Client) getChannels(ctx context.Context, spath string) (*ChannelsResponse, error) {
+	req, err := c.newRequest(ctx, "GET", spath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new request: %w", err)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dispatch request: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("non-success status code %d", resp.StatusCode)
+	}
+
+	var channels ChannelsResponse
+	if err := decodeBody(resp, &channels); err != nil {
+		return nil, fmt.Errorf("failed to decode response body: %w", err)
+	}
+
+	return &channels, nil
+}
+
+func (c *Client) GetChannel(ctx context.Context, channelType, channel string) (*Channel, error) {
+	req, err := c.newRequest(ctx, "GET", "/api/channels/"+url.PathEscape(channelType)+"/"+url.PathEscape(channel), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new request: %w", err)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dispatch request: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("non-success status code %d", resp.StatusCode)
+	}
+
+	var ch Channel
+	if err := decodeBody(resp, &ch); err != nil {
+		return nil, fmt.Errorf("failed to decode response body: %w", err)
+	}
+
+	return &ch, nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2021 coord_e
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//  	 http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirakurun
+
+import (
+	"context"
+	"fmt"
+)
+
+// ServerConfig is the content of server.yml. Durations are in milliseconds.
+type ServerConfig struct {
+	Path                      *string `json:"path"`
+	Port                      *int    `json:"port"`
+	Hostname                  *string `json:"hostname"`
+	DisableIPv6               *bool   `json:"disableIPv6"`
+	LogLevel                  *int    `json:"logLevel"`
+	MaxLogHistory             *int    `json:"maxLogHistory"`
+	JobMaxRunning             *int    `json:"jobMaxRunning"`
+	JobMaxStandby             *int    `json:"jobMaxStandby"`
+	MaxBufferBytesBeforeReady *int64  `json:"maxBufferBytesBeforeReady"`
+	EventEndTimeout           *int64  `json:"eventEndTimeout"`
+	ProgramGCInterval         *int64  `json:"programGCInterval"`
+	EPGGatheringInterval      *int64  `json:"epgGatheringInterval"`
+	EPGRetrievalTime          *int64  `json:"epgRetrievalTime"`
+	LogoDataInterval          *int64  `json:"logoDataInterval"`
+	DisableEITParsing         *bool   `json:"disableEITParsing"`
+	DisableWebUI              *bool   `json:"disableWebUI"`
+}
+
+// TunersConfig is the content of tuners.yml.
+type TunersConfig []struct {
+	Name                   string   `json:"name"`
+	Types                  []string `json:"types"`
+	Command                *string  `json:"command"`
+	DVBDevicePath          *string  `json:"dvbDevicePath"`
+	RemoteMirakurunHost    *string  `json:"remoteMirakurunHost"`
+	RemoteMirakurunPort    *int     `json:"remoteMirakurunPort"`
+	RemoteMirakurunDecoder *bool    `json:"remoteMirakurunDecoder"`
+	Decoder                *string  `json:"decoder"`
+	IsDisabled             *bool    `json:"isDisabled"`
+}
+
+// ChannelsConfig is the content of channels.yml.
+type ChannelsConfig []struct {
+	Name       string  `json:"name"`
+	Type       string  `json:"type"`
+	Channel    string  `json:"channel"`
+	ServiceID  *int    `json:"serviceId"`
+	Satelite   *string `json:"satelite"`
+	Space      *int    `json:"space"`
+	Freq       *int    `json:"freq"`
+	Polarity   *string `json:"polarity"`
+	TSMFRelTS  *int    `json:"tsmfRelTs"`
+	IsDisabled *bool   `json:"isDisabled"`
+}
+
+func (c *Client) GetServerConfig(ctx context.Context) (*ServerConfig, error) {
+	var config ServerConfig
+	if err := c.getConfig(ctx, "/api/config/server", &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+func (c *Client) GetTunersConfig(ctx context.Context) (*TunersConfig, error) {
+	var config TunersConfig
+	if err := c.getConfig(ctx, "/api/config/tuners", &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+func (c *Client) GetChannelsConfig(ctx context.Context) (*ChannelsConfig, error) {
+	var config ChannelsConfig
+	if err := c.getConfig(ctx, "/api/config/channels", &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+func (c *Client) getConfig(ctx context.Context, spath string, out interface{}) error {
+	req, err := c.newRequest(ctx, "GET", spath, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create new request: %w", err)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to dispatch request: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("non-success status code %d", resp.StatusCode)
+	}
+
+	if err := decodeBody(resp, out); err != nil {
+		return fmt.Errorf("failed to decode response body: %w", err)
+	}
+
+	return nil
+}
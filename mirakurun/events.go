@@ -0,0 +1,201 @@
+// Copyright 2021 coord_e
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//  	 http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirakurun
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/go-kit/log/level"
+)
+
+// Event is an event notified by Mirakurun.
+// Data holds a program, a service or a tuner depending on Resource.
+type Event struct {
+	Resource string          `json:"resource"`
+	Type     string          `json:"type"`
+	Data     json.RawMessage `json:"data"`
+	Time     int64           `json:"time"`
+}
+
+// EventsQuery filters events on the server side. Empty fields are not used for filtering.
+type EventsQuery struct {
+	Resource string
+	Type     string
+}
+
+func (q *EventsQuery) values() url.Values {
+	values := url.Values{}
+	if q == nil {
+		return values
+	}
+	if len(q.Resource) != 0 {
+		values.Set("resource", q.Resource)
+	}
+	if len(q.Type) != 0 {
+		values.Set("type", q.Type)
+	}
+	return values
+}
+
+// maxEventSize is the maximum size of a single event in the stream.
+const maxEventSize = 16 * 1024 * 1024
+
+// EventStream reads events from /api/events/stream.
+type EventStream struct {
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+}
+
+// OpenEventStream connects to /api/events/stream. The stream lasts until it is closed or ctx is cancelled.
+func (c *Client) OpenEventStream(ctx context.Context, query *EventsQuery) (*EventStream, error) {
+	req, err := c.newRequest(ctx, "GET", "/api/events/stream", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new request: %w", err)
+	}
+	req.URL.RawQuery = query.values().Encode()
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dispatch request: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("non-success status code %d", resp.StatusCode)
+	}
+
+	return newEventStream(resp.Body), nil
+}
+
+func newEventStream(body io.ReadCloser) *EventStream {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxEventSize)
+	return &EventStream{body: body, scanner: scanner}
+}
+
+// Next blocks until the next event arrives. It returns io.EOF when the stream ends.
+func (s *EventStream) Next() (*Event, error) {
+	for s.scanner.Scan() {
+		// the stream is a JSON array with an event per line, i.e. "[", "{...}", ",{...}", ...
+		line := bytes.Trim(s.scanner.Bytes(), "[], \t\r")
+		if len(line) == 0 {
+			continue
+		}
+
+		var event Event
+		if err := json.Unmarshal(line, &event); err != nil {
+			return nil, fmt.Errorf("failed to decode event: %w", err)
+		}
+		return &event, nil
+	}
+	if err := s.scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read event stream: %w", err)
+	}
+	return nil, io.EOF
+}
+
+func (s *EventStream) Close() error {
+	return s.body.Close()
+}
+
+// EventSubscriber keeps streaming events from Mirakurun, reconnecting with exponential backoff.
+type EventSubscriber struct {
+	Client     *Client
+	Query      *EventsQuery
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// StableDuration is how long a stream has to last for the backoff to be reset.
+	// A stream ending earlier is treated as a failure, so that a stream dropped right after connecting is not retried rapidly.
+	StableDuration time.Duration
+
+	// OnConnect and OnDisconnect are called when a stream starts and ends, if not nil.
+	OnConnect    func()
+	OnDisconnect func(error)
+}
+
+func NewEventSubscriber(client *Client, query *EventsQuery) *EventSubscriber {
+	return &EventSubscriber{
+		Client:         client,
+		Query:          query,
+		MinBackoff:     time.Second,
+		MaxBackoff:     time.Minute,
+		StableDuration: time.Minute,
+	}
+}
+
+// Run streams events and calls handler for each of them until ctx is cancelled.
+func (s *EventSubscriber) Run(ctx context.Context, handler func(*Event)) error {
+	backoff := s.MinBackoff
+	for {
+		startedAt := time.Now()
+		err := s.stream(ctx, handler)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		switch {
+		case err != nil:
+			level.Warn(s.Client.Logger).Log("msg", "failed to connect to event stream", "err", err, "backoff", backoff)
+		case time.Since(startedAt) >= s.StableDuration:
+			// the stream was stable; start over from the minimum backoff
+			backoff = s.MinBackoff
+			level.Info(s.Client.Logger).Log("msg", "event stream ended, reconnecting", "backoff", backoff)
+		default:
+			level.Warn(s.Client.Logger).Log("msg", "event stream ended shortly after connecting", "backoff", backoff)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > s.MaxBackoff {
+			backoff = s.MaxBackoff
+		}
+	}
+}
+
+// stream runs a single stream. It returns nil if the stream was established and ended, or an error if it failed to connect.
+func (s *EventSubscriber) stream(ctx context.Context, handler func(*Event)) error {
+	stream, err := s.Client.OpenEventStream(ctx, s.Query)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	if s.OnConnect != nil {
+		s.OnConnect()
+	}
+	for {
+		event, err := stream.Next()
+		if err != nil {
+			if s.OnDisconnect != nil {
+				s.OnDisconnect(err)
+			}
+			if !errors.Is(err, io.EOF) {
+				level.Warn(s.Client.Logger).Log("msg", "event stream failed", "err", err)
+			}
+			return nil
+		}
+		handler(event)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2021 coord_e
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//  	 http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mirakurun
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestEventStreamNext(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "empty array",
+			body: "[\n]",
+			want: nil,
+		},
+		{
+			name: "empty body",
+			body: "",
+			want: nil,
+		},
+		{
+			name: "separator after event",
+			body: "[\n" +
+				`{"resource":"program","type":"create","data":{"id":1},"time":1}` + "\n,\n" +
+				`{"resource":"service","type":"update","data":{"id":2},"time":2}` + "\n,\n",
+			want: []string{"program/create", "service/update"},
+		},
+		{
+			name: "separator before event",
+			body: "[\n" +
+				`{"resource":"program","type":"create","data":{"id":1},"time":1}` + "\n" +
+				`,{"resource":"tuner","type":"update","data":{"index":0},"time":2}` + "\n" +
+				"]\n",
+			want: []string{"program/create", "tuner/update"},
+		},
+		{
+			name: "CRLF and blank lines",
+			body: "[\r\n\r\n" +
+				`{"resource":"program","type":"redefine","data":{"from":1,"to":2},"time":1}` + "\r\n,\r\n",
+			want: []string{"program/redefine"},
+		},
+		{
+			name: "data ending with brackets",
+			body: "[\n" +
+				`{"resource":"program","type":"update","data":{"id":1,"genres":[]},"time":1}` + "\n" +
+				"]\n",
+			want: []string{"program/update"},
+		},
+		{
+			name:    "broken event",
+			body:    "[\n" + `{"resource":"program",` + "\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		stream := newEventStream(io.NopCloser(strings.NewReader(tt.body)))
+		var got []string
+		var err error
+		for {
+			var event *Event
+			event, err = stream.Next()
+			if err != nil {
+				break
+			}
+			got = append(got, event.Resource+"/"+event.Type)
+		}
+
+		if tt.wantErr {
+			if errors.Is(err, io.EOF) {
+				t.Errorf("%s: Next() returned io.EOF, want decode error", tt.name)
+			}
+			continue
+		}
+		if !errors.Is(err, io.EOF) {
+			t.Errorf("%s: Next() returned %v, want io.EOF", tt.name, err)
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s: events = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEventsQueryValues(t *testing.T) {
+	tests := []struct {
+		query *EventsQuery
+		want  string
+	}{
+		{query: nil, want: ""},
+		{query: &EventsQuery{}, want: ""},
+		{query: &EventsQuery{Resource: "program"}, want: "resource=program"},
+		{query: &EventsQuery{Resource: "program", Type: "redefine"}, want: "resource=program&type=redefine"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.query.values().Encode(); got != tt.want {
+			t.Errorf("values() of %+v = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
@@ -16,9 +16,11 @@ package mirakurun
 import (
 	"context"
 	"fmt"
+	"net/url"
+	"strconv"
 )
 
-type ProgramsResponse []struct {
+type Program struct {
 	ID          int64   `json:"id"`
 	EventID     int     `json:"eventId"`
 	ServiceID   int     `json:"serviceId"`
@@ -65,11 +67,39 @@ type ProgramsResponse []struct {
 	} `json:"relatedItems"`
 }
 
-func (c *Client) GetPrograms(ctx context.Context) (*ProgramsResponse, error) {
+type ProgramsResponse []Program
+
+// ProgramsQuery filters programs on the server side. Nil fields are not used for filtering.
+type ProgramsQuery struct {
+	NetworkID *int
+	ServiceID *int
+	EventID   *int
+}
+
+func (q *ProgramsQuery) values() url.Values {
+	values := url.Values{}
+	if q == nil {
+		return values
+	}
+	if q.NetworkID != nil {
+		values.Set("networkId", strconv.Itoa(*q.NetworkID))
+	}
+	if q.ServiceID != nil {
+		values.Set("serviceId", strconv.Itoa(*q.ServiceID))
+	}
+	if q.EventID != nil {
+		values.Set("eventId", strconv.Itoa(*q.EventID))
+	}
+	return values
+}
+
+// GetPrograms fetches programs matching the query. All programs are fetched if query is nil.
+func (c *Client) GetPrograms(ctx context.Context, query *ProgramsQuery) (*ProgramsResponse, error) {
 	req, err := c.newRequest(ctx, "GET", "/api/programs", nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create new request: %w", err)
 	}
+	req.URL.RawQuery = query.values().Encode()
 
 	resp, err := c.HTTPClient.Do(req)
 	if err != nil {
@@ -87,3 +117,31 @@ func (c *Client) GetPrograms(ctx context.Context) (*ProgramsResponse, error) {
 
 	return &programs, nil
 }
+
+// GetServicePrograms fetches programs of the service.
+func (c *Client) GetServicePrograms(ctx context.Context, networkID, serviceID int) (*ProgramsResponse, error) {
+	return c.GetPrograms(ctx, &ProgramsQuery{NetworkID: &networkID, ServiceID: &serviceID})
+}
+
+func (c *Client) GetProgram(ctx context.Context, id int64) (*Program, error) {
+	req, err := c.newRequest(ctx, "GET", "/api/programs/"+strconv.FormatInt(id, 10), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create new request: %w", err)
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dispatch request: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("non-success status code %d", resp.StatusCode)
+	}
+
+	var program Program
+	if err := decodeBody(resp, &program); err != nil {
+		return nil, fmt.Errorf("failed to decode response body: %w", err)
+	}
+
+	return &program, nil
+}
@@ -18,7 +18,7 @@ import (
 	"fmt"
 )
 
-type ServicesResponse []struct {
+type Service struct {
 	ID                 int64  `json:"id"`
 	ServiceID          int    `json:"serviceId"`
 	NetworkID          int    `json:"networkId"`
@@ -43,6 +43,8 @@ type ServicesResponse []struct {
 	HasLogoData *bool `json:"hasLogoData"`
 }
 
+type ServicesResponse []Service
+
 func (c *Client) GetServices(ctx context.Context) (*ServicesResponse, error) {
 	req, err := c.newRequest(ctx, "GET", "/api/services", nil)
 	if err != nil {
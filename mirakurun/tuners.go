@@ -18,7 +18,7 @@ import (
 	"fmt"
 )
 
-type TunersResponse []struct {
+type Tuner struct {
 	Index   int      `json:"index"`
 	Name    string   `json:"name"`
 	Types   []string `json:"types"`
@@ -63,6 +63,8 @@ type TunersResponse []struct {
 	IsFault     bool `json:"isFault"`
 }
 
+type TunersResponse []Tuner
+
 func (c *Client) GetTuners(ctx context.Context) (*TunersResponse, error) {
 	req, err := c.newRequest(ctx, "GET", "/api/tuners", nil)
 	if err != nil {